@@ -1,4 +1,4 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import {
   Form,
   Input,
@@ -12,7 +12,8 @@ import {
 import {
   UserOutlined,
   LockOutlined,
-  BookOutlined
+  BookOutlined,
+  SafetyOutlined
 } from '@ant-design/icons';
 import { useNavigate } from 'react-router-dom';
 import axios from '../utils/axios';
@@ -22,25 +23,70 @@ const { Title, Text } = Typography;
 interface LoginForm {
   username: string;
   password: string;
+  captcha_value: string;
+  code?: string;
+}
+
+interface Captcha {
+  captcha_id: string;
+  captchaBase64: string;
 }
 
 const Login: React.FC = () => {
   const [loading, setLoading] = useState(false);
+  const [captcha, setCaptcha] = useState<Captcha | null>(null);
+  // 开启了两步验证的管理员，第一步登录返回挑战token，需要再提交动态码
+  const [challengeToken, setChallengeToken] = useState('');
   const navigate = useNavigate();
 
+  // 获取图片验证码，登录失败后需要刷新（验证码只能使用一次）
+  const fetchCaptcha = async () => {
+    try {
+      const response = await axios.get('/api/v1/captcha/generate');
+      if (response.data.code === 0) {
+        setCaptcha(response.data.data);
+      }
+    } catch (error) {
+      message.error('获取验证码失败');
+    }
+  };
+
+  useEffect(() => {
+    fetchCaptcha();
+  }, []);
+
+  // 保存token和用户信息，字段与后端 LoginResponse 保持一致
+  const saveLogin = (data: any) => {
+    localStorage.setItem('admin_token', data.access_token);
+    localStorage.setItem('admin_user', JSON.stringify(data.user_info));
+    message.success('登录成功');
+    navigate('/');
+  };
+
   const handleLogin = async (values: LoginForm) => {
     setLoading(true);
     try {
-      const response = await axios.post('/api/v1/admin/auth/login', values);
+      const response = challengeToken
+        ? await axios.post('/api/v1/admin/auth/login/2fa', {
+            challenge_token: challengeToken,
+            code: values.code,
+          })
+        : await axios.post('/api/v1/admin/auth/login', {
+            username: values.username,
+            password: values.password,
+            captcha_id: captcha?.captcha_id,
+            captcha_value: values.captcha_value,
+          });
       if (response.data.code === 0) {
-        // 保存token和用户信息
-        localStorage.setItem('admin_token', response.data.data.token);
-        localStorage.setItem('admin_user', JSON.stringify(response.data.data.user));
-        
-        message.success('登录成功');
-        navigate('/');
+        if (response.data.data.two_factor_required) {
+          setChallengeToken(response.data.data.challenge_token);
+          message.info('请输入两步验证动态码');
+          return;
+        }
+        saveLogin(response.data.data);
       } else {
         message.error(response.data.message);
+        fetchCaptcha();
       }
     } catch (error: any) {
       if (error.response) {
@@ -48,6 +94,9 @@ const Login: React.FC = () => {
       } else {
         message.error('网络错误，请检查连接');
       }
+      if (!challengeToken) {
+        fetchCaptcha();
+      }
     } finally {
       setLoading(false);
     }
@@ -89,29 +138,83 @@ const Login: React.FC = () => {
           autoComplete="off"
           layout="vertical"
         >
-          <Form.Item
-            name="username"
-            rules={[{ required: true, message: '请输入用户名' }]}
-          >
-            <Input
-              prefix={<UserOutlined />}
-              placeholder="用户名"
-              size="large"
-              style={{ borderRadius: 8 }}
-            />
-          </Form.Item>
+          {challengeToken ? (
+            <Form.Item
+              name="code"
+              rules={[{ required: true, message: '请输入动态码或恢复码' }]}
+            >
+              <Input
+                prefix={<SafetyOutlined />}
+                placeholder="动态码或恢复码"
+                size="large"
+                style={{ borderRadius: 8 }}
+              />
+            </Form.Item>
+          ) : (
+            <>
+              <Form.Item
+                name="username"
+                rules={[{ required: true, message: '请输入用户名' }]}
+              >
+                <Input
+                  prefix={<UserOutlined />}
+                  placeholder="用户名"
+                  size="large"
+                  style={{ borderRadius: 8 }}
+                />
+              </Form.Item>
 
-          <Form.Item
-            name="password"
-            rules={[{ required: true, message: '请输入密码' }]}
-          >
-            <Input.Password
-              prefix={<LockOutlined />}
-              placeholder="密码"
-              size="large"
-              style={{ borderRadius: 8 }}
-            />
-          </Form.Item>
+              <Form.Item
+                name="password"
+                rules={[{ required: true, message: '请输入密码' }]}
+              >
+                <Input.Password
+                  prefix={<LockOutlined />}
+                  placeholder="密码"
+                  size="large"
+                  style={{ borderRadius: 8 }}
+                />
+              </Form.Item>
+
+              <Form.Item>
+                <div style={{ display: 'flex', alignItems: 'center' }}>
+                  <Form.Item
+                    name="captcha_value"
+                    noStyle
+                    rules={[{ required: true, message: '请输入验证码' }]}
+                  >
+                    <Input
+                      prefix={<SafetyOutlined />}
+                      placeholder="验证码"
+                      size="large"
+                      style={{ borderRadius: 8 }}
+                    />
+                  </Form.Item>
+                  {captcha && (captcha.captchaBase64.startsWith('data:audio') ? (
+                    // 服务端配置为音频验证码时返回 WAV，用播放器代替图片
+                    <>
+                      <audio
+                        controls
+                        src={captcha.captchaBase64}
+                        style={{ height: 40, marginLeft: 8 }}
+                      />
+                      <Button type="link" onClick={fetchCaptcha}>
+                        换一个
+                      </Button>
+                    </>
+                  ) : (
+                    <img
+                      src={captcha.captchaBase64}
+                      alt="验证码"
+                      title="点击刷新"
+                      onClick={fetchCaptcha}
+                      style={{ height: 40, marginLeft: 8, cursor: 'pointer' }}
+                    />
+                  ))}
+                </div>
+              </Form.Item>
+            </>
+          )}
 
           <Form.Item>
             <Button
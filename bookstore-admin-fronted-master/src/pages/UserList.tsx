@@ -123,12 +123,12 @@ const UserList: React.FC = () => {
     }
   };
 
-  // 删除用户
+  // 删除用户（后端保留订单等历史数据，只禁用账号）
   const handleDelete = async (id: number) => {
     try {
       const response = await axios.delete(`/api/v1/admin/users/${id}`);
       if (response.data.code === 0) {
-        message.success('删除成功');
+        message.success('用户已禁用');
         fetchUsers();
       } else {
         message.error(response.data.message);
@@ -138,10 +138,10 @@ const UserList: React.FC = () => {
     }
   };
 
-  // 更新用户状态
+  // 设置/取消管理员
   const handleStatusChange = async (id: number, isAdmin: boolean) => {
     try {
-      const response = await axios.put(`/api/v1/admin/users/${id}/status`, { is_admin: isAdmin });
+      const response = await axios.put(`/api/v1/admin/users/${id}/admin`, { is_admin: isAdmin });
       if (response.data.code === 0) {
         message.success('状态更新成功');
         fetchUsers();
@@ -233,7 +233,7 @@ const UserList: React.FC = () => {
             编辑
           </Button>
           <Popconfirm
-            title="确定要删除这个用户吗？"
+            title="确定要删除这个用户吗？删除后该账号将被禁用"
             onConfirm={() => handleDelete(record.id)}
             okText="确定"
             cancelText="取消"
@@ -4,17 +4,23 @@ import (
 	"bookstore-manager/config"
 	"bookstore-manager/core"
 	"bookstore-manager/global"
+	"bookstore-manager/jwt"
+	"bookstore-manager/mail"
 	"bookstore-manager/model"
 	"bookstore-manager/mq"
+	"bookstore-manager/payment"
+	"bookstore-manager/repository"
 	"bookstore-manager/service"
 	"bookstore-manager/utils/snowflake"
 	"bookstore-manager/web/router"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -39,23 +45,48 @@ func StartOrderConsumer(orderService *service.OrderService) {
 
 		// 2. 调用 Service 落库
 		err := orderService.CreateOrderInDB(&msg)
-		if err != nil {
-			if err.Error() == "库存不足" {
-				global.Logger.Warn("业务失败(无库存)", zap.Error(err))
-				d.Ack(false) // 业务失败，确认消费（不再重试）
-			} else {
-				global.Logger.Error("系统失败(DB抖动), 准备重试", zap.Error(err))
-				// Nack(multiple=false, requeue=true)
-				// requeue=true 表示把消息放回队列头部，让别人（或者自己）再试一次
-				d.Nack(false, true)
-			}
-		} else {
+		switch {
+		case err == nil:
 			global.Logger.Info("成功落库", zap.String("orderNo", msg.OrderNo))
 			d.Ack(false) // 成功，确认消费
+		case errors.Is(err, repository.ErrStockInsufficient):
+			// 业务失败，Redis 中预占的库存已归还，确认消费（不再重试）
+			global.Logger.Warn("业务失败(无库存)", zap.String("orderNo", msg.OrderNo), zap.Error(err))
+			d.Ack(false)
+		case orderService.RetryOrderMessage(&msg):
+			global.Logger.Error("系统失败(DB抖动), 准备重试", zap.String("orderNo", msg.OrderNo), zap.Error(err))
+			// Nack(multiple=false, requeue=true)
+			// requeue=true 表示把消息放回队列头部，让别人（或者自己）再试一次
+			d.Nack(false, true)
+		default:
+			// 重试次数用尽：归还库存后转入死信队列，等待人工排查
+			global.Logger.Error("多次落库失败，转入死信队列", zap.String("orderNo", msg.OrderNo), zap.Error(err))
+			if err := orderService.AbandonOrderMessage(&msg, "系统繁忙，下单失败"); err != nil {
+				global.Logger.Error("归还预占库存失败，需人工处理", zap.String("orderNo", msg.OrderNo), zap.Error(err))
+			}
+			d.Nack(false, false)
 		}
 	})
 }
 
+// StartOrderTimeoutConsumer 处理超时未支付的订单，消息内容为订单ID
+func StartOrderTimeoutConsumer(orderService *service.OrderService) {
+	mq.StartConsumer(mq.OrderTimeoutKey, func(msgStr string, d amqp.Delivery) {
+		orderID, err := strconv.ParseInt(msgStr, 10, 64)
+		if err != nil {
+			global.Logger.Error("订单超时消息格式错误，丢弃", zap.String("msg", msgStr))
+			d.Ack(false)
+			return
+		}
+		if err := orderService.CancelExpiredOrder(orderID); err != nil {
+			global.Logger.Error("自动取消订单失败, 准备重试", zap.Int64("orderID", orderID), zap.Error(err))
+			d.Nack(false, true)
+			return
+		}
+		d.Ack(false)
+	})
+}
+
 // warmUpData 数据预热：库存 + 排行榜
 func warmUpData() {
 	var books []model.Book
@@ -98,22 +129,75 @@ func warmUpData() {
 	)
 }
 
+// snowflakeNodeID 确定本实例的雪花节点ID：环境变量 BOOKSTORE_NODE_ID 优先，其次是配置文件。
+// 单实例部署未配置时使用 1；多实例部署必须显式配置，否则各实例可能生成相同的ID
+func snowflakeNodeID(cfg config.ServerConfig) (int64, error) {
+	var nodeID int64
+	if env := os.Getenv("BOOKSTORE_NODE_ID"); env != "" {
+		id, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("环境变量 BOOKSTORE_NODE_ID 不是整数: %q", env)
+		}
+		nodeID = id
+	} else if cfg.NodeID != nil {
+		nodeID = *cfg.NodeID
+	} else if cfg.MultiInstance {
+		return 0, errors.New("多实例部署必须配置 server.node_id 或环境变量 BOOKSTORE_NODE_ID")
+	} else {
+		nodeID = 1
+	}
+	if nodeID < 0 || nodeID > 1023 {
+		return 0, fmt.Errorf("雪花节点ID必须在0-1023之间: %d", nodeID)
+	}
+	return nodeID, nil
+}
+
 func main() {
 	// 1. 初始化基础架构 (Infrastructure Initialization)
 	core.InitLogger()                                    // 初始化日志 (最先初始化)
 	config.InitConfig("conf/config.yaml", global.Logger) // 加载配置 (传入 Logger)
 
-	//初始化雪花算法 (时间戳: 2025-12-26, 机器ID: 1)
-	if err := snowflake.Init("2023-12-01", 1); err != nil {
+	// 加载JWT签名密钥
+	if err := jwt.InitKeys(config.AppConfig.JWT); err != nil {
+		global.Logger.Fatal("JWT密钥加载失败", zap.Error(err))
+	}
+
+	//初始化雪花算法 (起始时间: 2023-12-01, 机器ID 来自配置或环境变量)
+	nodeID, err := snowflakeNodeID(config.AppConfig.Server)
+	if err != nil {
+		global.Logger.Fatal("雪花算法节点ID配置错误", zap.Error(err))
+	}
+	if err := snowflake.Init("2023-12-01", nodeID); err != nil {
 		global.Logger.Fatal("雪花算法初始化失败", zap.Error(err))
 	}
+	global.Logger.Info("雪花算法初始化成功", zap.Int64("nodeID", nodeID))
 
 	global.InitMysql() // 初始化 MySQL
 	global.InitRedis() // 初始化 Redis
 	mq.InitRabbitMQ()  // 初始化 RabbitMQ
 
+	// 初始化邮件服务
+	if err := mail.InitMailer(); err != nil {
+		global.Logger.Fatal("邮件服务初始化失败", zap.Error(err))
+	}
+
+	// 初始化支付渠道
+	if err := payment.InitPayment(); err != nil {
+		global.Logger.Fatal("支付服务初始化失败", zap.Error(err))
+	}
+
+	// 初始化内置角色和权限
+	if err := service.NewRBACService().SeedDefaults(); err != nil {
+		global.Logger.Fatal("初始化角色权限失败", zap.Error(err))
+	}
+
 	// 2. 数据预热 (Data Warm-up)
 	warmUpData()
+	service.NewSeckillService().WarmUpCampaigns()
+	// 校正分类下的图书数量 (book_count 为物化字段)
+	if err := repository.NewCategoryDAO().RefreshAllBookCounts(); err != nil {
+		global.Logger.Error("校正分类图书数量失败", zap.Error(err))
+	}
 
 	// 3. 初始化业务服务 (Service Initialization)
 	orderService := service.NewOrderService()
@@ -134,6 +218,9 @@ func main() {
 	// 4.3 处理秒杀订单 (核心异步逻辑)
 	StartOrderConsumer(orderService)
 
+	// 4.4 自动取消超时未支付的订单
+	StartOrderTimeoutConsumer(orderService)
+
 	// 5. 启动 HTTP 服务器
 	r := router.InitRouter()
 	addr := fmt.Sprintf(":%d", config.AppConfig.Server.Port)
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"strings"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -8,6 +10,13 @@ import (
 
 type ServerConfig struct {
 	Port int `mapstructure:"port"`
+	// 可信的反向代理地址(IP 或 CIDR)，只有来自这些地址的请求才会采信 X-Forwarded-For。
+	// 为空表示不信任任何代理，ClientIP 取连接的对端地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// 雪花算法节点ID(0-1023)，多实例部署时每个实例必须不同，也可以用环境变量 BOOKSTORE_NODE_ID 指定
+	NodeID *int64 `mapstructure:"node_id"`
+	// 多实例部署时必须显式配置 NodeID，否则拒绝启动，避免不同实例生成重复的ID和订单号
+	MultiInstance bool `mapstructure:"multi_instance"`
 }
 
 type DatabaseConfig struct {
@@ -33,11 +42,80 @@ type RabbitMQConfig struct {
 	VHost    string `mapstructure:"vhost"`
 }
 
+type SecurityConfig struct {
+	PasswordCost int    `mapstructure:"password_cost"` // bcrypt 计算成本(4-31)，不配置时使用默认值 10
+	TokenSecret  string `mapstructure:"token_secret"`  // 重置密码、验证邮箱等一次性token的签名密钥
+
+	LoginMaxFailures   int `mapstructure:"login_max_failures"`    // 同一用户名连续登录失败多少次后锁定，默认 5
+	LoginIPMaxFailures int `mapstructure:"login_ip_max_failures"` // 同一IP登录失败多少次后锁定，默认 20
+	LoginLockMinutes   int `mapstructure:"login_lock_minutes"`    // 锁定时长(分钟)，也是失败次数的统计窗口，默认 15
+}
+
+type MailConfig struct {
+	Driver   string `mapstructure:"driver"`   // smtp 或 log，log 只把邮件写入日志/文件，用于开发和测试
+	Host     string `mapstructure:"host"`     // SMTP 服务器
+	Port     int    `mapstructure:"port"`     // 465 使用 SSL，其他端口在服务器支持时使用 STARTTLS
+	Username string `mapstructure:"username"` // SMTP 账号
+	Password string `mapstructure:"password"` // SMTP 密码或授权码
+	From     string `mapstructure:"from"`     // 发件人地址
+	LogFile  string `mapstructure:"log_file"` // log 驱动写入的文件，为空时只写日志
+	SiteURL  string `mapstructure:"site_url"` // 前端地址，用于拼接邮件中的链接
+}
+
+// JWTKeyConfig 一把JWT密钥。HS256 使用 secret；RS256/EdDSA 使用 PEM 文件，
+// 只做验证的旧密钥可以只配置公钥
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"id"`               // 写入token头部的 kid
+	Algorithm      string `mapstructure:"algorithm"`        // HS256、RS256 或 EdDSA
+	Secret         string `mapstructure:"secret"`           // HS256 密钥
+	PrivateKeyFile string `mapstructure:"private_key_file"` // PKCS#8/PKCS#1 私钥
+	PublicKeyFile  string `mapstructure:"public_key_file"`  // PKIX 公钥，不配置时从私钥推导
+}
+
+type JWTConfig struct {
+	SigningKey string         `mapstructure:"signing_key"` // 当前用于签发token的密钥 kid
+	Keys       []JWTKeyConfig `mapstructure:"keys"`        // 所有可用于验证的密钥
+}
+
+type CaptchaConfig struct {
+	Type          string `mapstructure:"type"`           // digit、math、string 或 audio，默认 digit
+	Length        int    `mapstructure:"length"`         // 字符个数(math 类型无效)，默认 4
+	Width         int    `mapstructure:"width"`          // 图片宽度，默认 240
+	Height        int    `mapstructure:"height"`         // 图片高度，默认 80
+	NoiseCount    int    `mapstructure:"noise_count"`    // 干扰点/字符数量
+	AudioLanguage string `mapstructure:"audio_language"` // 语音验证码语言：zh、en、ja、ru，默认 zh
+	ExpireSeconds int    `mapstructure:"expire_seconds"` // 有效期(秒)，默认 120
+	MaxAttempts   int    `mapstructure:"max_attempts"`   // 同一个验证码最多校验几次，默认 3
+}
+
+type OrderConfig struct {
+	PaymentTimeoutMinutes int `mapstructure:"payment_timeout_minutes"` // 下单后多久未支付自动取消，默认 30
+}
+
+type MockPaymentConfig struct {
+	// Enabled 本地模拟网关只用于开发测试，必须显式打开；模拟付款接口不需要登录，不能出现在生产环境
+	Enabled              bool   `mapstructure:"enabled"`
+	Secret               string `mapstructure:"secret"`                 // 回调签名密钥
+	CallbackDelaySeconds int    `mapstructure:"callback_delay_seconds"` // 模拟付款后多久发送回调，默认 2
+}
+
+type PaymentConfig struct {
+	Provider string            `mapstructure:"provider"` // 支付渠道，目前支持 mock，必须显式配置
+	BaseURL  string            `mapstructure:"base_url"` // 本服务对外地址，用于拼接回调地址和模拟收银台地址
+	Mock     MockPaymentConfig `mapstructure:"mock"`
+}
+
 type Config struct {
 	Server   ServerConfig   `mapstructure:"server"`
 	Database DatabaseConfig `mapstructure:"database"`
 	Redis    RedisConfig    `mapstructure:"redis"`
 	RabbitMQ RabbitMQConfig `mapstructure:"rabbitmq"`
+	Security SecurityConfig `mapstructure:"security"`
+	JWT      JWTConfig      `mapstructure:"jwt"`
+	Mail     MailConfig     `mapstructure:"mail"`
+	Captcha  CaptchaConfig  `mapstructure:"captcha"`
+	Order    OrderConfig    `mapstructure:"order"`
+	Payment  PaymentConfig  `mapstructure:"payment"`
 }
 
 // 全局配置变量
@@ -58,6 +136,11 @@ func InitConfig(path string, logger *zap.Logger) {
 		zapLog.Panic("读取配置文件失败", zap.Error(err))
 	}
 
+	// 环境变量优先于配置文件，例如 BOOKSTORE_PAYMENT_MOCK_ENABLED 对应 payment.mock.enabled
+	v.SetEnvPrefix("BOOKSTORE")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
 	// 3. 开启实时监听 (热加载)
 	v.WatchConfig()
 	v.OnConfigChange(func(e fsnotify.Event) {
@@ -29,7 +29,8 @@ func InitMysql() {
 	if err != nil {
 		Logger.Fatal("连接数据库失败：", zap.Error(err))
 	}
-	if err := client.AutoMigrate(&model.User{}, &model.Book{}, &model.Category{}, &model.Order{}, &model.OrderItem{}, &model.Favorite{}); err != nil {
+	if err := client.AutoMigrate(&model.User{}, &model.Book{}, &model.Category{}, &model.Order{}, &model.OrderItem{}, &model.OrderStatusHistory{}, &model.Payment{}, &model.Refund{}, &model.RefundItem{}, &model.Favorite{}, &model.SeckillCampaign{},
+		&model.Permission{}, &model.Role{}, &model.DataMigration{}); err != nil {
 		Logger.Fatal("自动迁移表失败：", zap.Error(err))
 	}
 	DBClient = client
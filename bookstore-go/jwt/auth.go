@@ -3,6 +3,8 @@ package jwt
 import (
 	"bookstore-manager/global"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
@@ -10,9 +12,6 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// jwtSecret JWT密钥（建议通过配置文件或环境变量设置）
-var jwtSecret = []byte("bookstore_secret_key")
-
 const (
 	//AccessTokenExpire 访问token过期时间
 	AccessTokenExpire = 2 * time.Hour
@@ -20,11 +19,20 @@ const (
 	RefreshTokenExpire = 7 * 24 * time.Hour
 )
 
+const (
+	//RoleUser 普通用户
+	RoleUser = "user"
+	//RoleAdmin 管理员，只能通过后台登录接口获得
+	RoleAdmin = "admin"
+)
+
 // Claims JWT声明结构体
 type Claims struct {
 	UserID    uint   `json:"user_id"`    //用户id
 	Username  string `json:"username"`   //用户名
 	TokenType string `json:"token_type"` //token类型："access"或"refresh"
+	Role      string `json:"role"`       //角色："user"或"admin"
+	SessionID string `json:"sid"`        //会话id，同一次登录刷新出的token共用
 	jwt.RegisteredClaims
 }
 
@@ -35,21 +43,39 @@ type TokenResponse struct {
 	ExpiresIn    int64  `json:"expires_in"`    //过期时间(秒)
 }
 
-// GenerateTokenPair 生成访问token和刷新token
-func GenerateTokenPair(userID uint, username string) (*TokenResponse, error) {
+// GenerateTokenPair 为一次新的登录创建会话并签发访问token和刷新token，
+// role 和会话ID会写入两个token的声明中
+func GenerateTokenPair(userID uint, username, role string, meta *SessionMeta) (*TokenResponse, error) {
+	sessionID := newTokenID()
+	token, err := signTokenPair(userID, username, role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	//将token存储到Redis
+	if err := StoreTokenInRedis(userID, sessionID, role, token, meta); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// signTokenPair 签发属于指定会话的一对token
+func signTokenPair(userID uint, username, role, sessionID string) (*TokenResponse, error) {
 	//生成访问token
 	accessClaims := Claims{
 		UserID:    userID,
 		Username:  username,
 		TokenType: "access",
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenExpire)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(jwtSecret)
+	accessTokenString, err := signClaims(accessClaims)
 	if err != nil {
 		return nil, err
 	}
@@ -59,62 +85,65 @@ func GenerateTokenPair(userID uint, username string) (*TokenResponse, error) {
 		UserID:    userID,
 		Username:  username,
 		TokenType: "refresh",
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenExpire)),
+			ID:        newTokenID(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(RefreshTokenExpire)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(jwtSecret)
+	refreshTokenString, err := signClaims(refreshClaims)
 	if err != nil {
 		return nil, err
 	}
 
-	//将token存储到Redis
-	if err := StoreTokenInRedis(userID, accessTokenString, refreshTokenString); err != nil {
-		return nil, err
-	}
 	return &TokenResponse{
 		AccessToken:  accessTokenString,
 		RefreshToken: refreshTokenString,
 		ExpiresIn:    int64(AccessTokenExpire.Seconds()),
 	}, nil
-
 }
 
-func StoreTokenInRedis(userID uint, accessToken, refreshToken string) error {
+// StoreTokenInRedis 新建一条会话记录，并登记到用户的会话列表中
+func StoreTokenInRedis(userID uint, sessionID, role string, token *TokenResponse, meta *SessionMeta) error {
 	ctx := context.Background()
-	userKey := fmt.Sprintf("user_tokens:%d", userID)
-
-	//使用hash存储用户的token信息
-	err := global.RedisClient.HMSet(ctx, userKey,
-		"access_token", accessToken,
-		"refresh_token", refreshToken,
-		"created_at", time.Now().Unix(),
-	).Err()
-	if err != nil {
-		return err
+	if meta == nil {
+		meta = &SessionMeta{}
 	}
-
+	now := time.Now().Unix()
+	sessionKey := sessionKey(userID, sessionID)
+	indexKey := sessionIndexKey(userID)
+
+	//使用hash存储每个会话的token和设备信息
+	pipe := global.RedisClient.TxPipeline()
+	pipe.HSet(ctx, sessionKey,
+		"access_token", token.AccessToken,
+		"refresh_token", token.RefreshToken,
+		"role", role,
+		"device", meta.DeviceName,
+		"ip", meta.IP,
+		"user_agent", meta.UserAgent,
+		"created_at", now,
+		"last_seen", now,
+	)
 	//设置过期时间为刷新token的过期时间
-	return global.RedisClient.Expire(ctx, userKey, RefreshTokenExpire).Err()
+	pipe.Expire(ctx, sessionKey, RefreshTokenExpire)
+	pipe.SAdd(ctx, indexKey, sessionID)
+	pipe.Expire(ctx, indexKey, RefreshTokenExpire)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// IsTokenValidInRedis 检查token是否在Redis中有效
-func IsTokenValidInRedis(userID uint, token string, tokenType string) bool {
+// IsTokenValidInRedis 检查token是否仍是所属会话当前有效的token
+func IsTokenValidInRedis(userID uint, sessionID, token, tokenType string) bool {
 	ctx := context.Background()
-	userKey := fmt.Sprintf("user_tokens:%d", userID)
-
-	var redisToken string
-	var err error
-
+	field := "refresh_token"
 	if tokenType == "access" {
-		redisToken, err = global.RedisClient.HGet(ctx, userKey, "access_token").Result()
-	} else {
-		redisToken, err = global.RedisClient.HGet(ctx, userKey, "refresh_token").Result()
+		field = "access_token"
 	}
-
+	redisToken, err := global.RedisClient.HGet(ctx, sessionKey(userID, sessionID), field).Result()
 	if err != nil {
 		return false
 	}
@@ -123,25 +152,51 @@ func IsTokenValidInRedis(userID uint, token string, tokenType string) bool {
 
 // 解析和校验JWT Token
 func ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	claims, err := parseClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	//检查token是否在Redis中被撤销
+	if !IsTokenValidInRedis(claims.UserID, claims.SessionID, tokenString, claims.TokenType) {
+		return nil, errors.New("token已被撤销")
+	}
+	if claims.TokenType == "access" {
+		touchSession(claims.UserID, claims.SessionID)
+	}
+	return claims, nil
+}
+
+// parseClaims 只校验签名和有效期，不检查Redis中的撤销状态
+func parseClaims(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verifyKey, jwt.WithValidMethods(validMethods()))
 	if err != nil {
 		return nil, err
 	}
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		//检查token是否在Redis中被撤销
-		if !IsTokenValidInRedis(claims.UserID, tokenString, claims.TokenType) {
-			return nil, errors.New("token已被撤销")
-		}
 		return claims, nil
 	}
 	return nil, errors.New("invalid token")
 }
 
-//撤销用户的所有token
-func RevokeToken(userID uint)error{
-	ctx:=context.Background()
-	userKey:=fmt.Sprintf("user_tokens:%d",userID)
-	return global.RedisClient.Del(ctx,userKey).Err()
-}
\ No newline at end of file
+// newTokenID 生成随机的 jti，保证同一秒内签发的token也互不相同
+func newTokenID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RevokeToken 撤销用户所有会话的token
+func RevokeToken(userID uint) error {
+	ctx := context.Background()
+	indexKey := sessionIndexKey(userID)
+	sessionIDs, err := global.RedisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return err
+	}
+	//同时清理升级前按用户存储的旧token记录
+	keys := []string{indexKey, fmt.Sprintf("user_tokens:%d", userID)}
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, sessionKey(userID, sessionID))
+	}
+	return global.RedisClient.Del(ctx, keys...).Err()
+}
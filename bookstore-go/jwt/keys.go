@@ -0,0 +1,289 @@
+package jwt
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// legacyKeyID 引入 kid 之前签发的token没有 kid 头，用这个 id 的密钥验证
+const legacyKeyID = "default"
+
+// legacySecret 未配置任何密钥时使用的旧版密钥，仅为兼容，生产环境必须在配置中替换
+const legacySecret = "bookstore_secret_key"
+
+// signingKey 一把已加载的密钥，非对称算法只配置公钥时 private 为空，只能用于验证
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+type keySet struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+	methods []string
+}
+
+var currentKeys atomic.Pointer[keySet]
+
+// InitKeys 根据配置加载签名和验证密钥，需要在签发或解析token之前调用
+func InitKeys(cfg config.JWTConfig) error {
+	if len(cfg.Keys) == 0 {
+		global.Logger.Warn("未配置JWT密钥，使用内置的默认密钥，请尽快在配置文件中更换")
+		cfg = config.JWTConfig{
+			SigningKey: legacyKeyID,
+			Keys: []config.JWTKeyConfig{
+				{ID: legacyKeyID, Algorithm: "HS256", Secret: legacySecret},
+			},
+		}
+	}
+
+	set := &keySet{keys: make(map[string]*signingKey, len(cfg.Keys))}
+	seenMethods := make(map[string]bool)
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return errors.New("JWT密钥缺少id")
+		}
+		if _, ok := set.keys[kc.ID]; ok {
+			return fmt.Errorf("JWT密钥id重复: %s", kc.ID)
+		}
+		key, err := loadKey(kc)
+		if err != nil {
+			return fmt.Errorf("加载JWT密钥 %s 失败: %w", kc.ID, err)
+		}
+		set.keys[kc.ID] = key
+		if !seenMethods[key.method.Alg()] {
+			seenMethods[key.method.Alg()] = true
+			set.methods = append(set.methods, key.method.Alg())
+		}
+	}
+
+	signing, ok := set.keys[cfg.SigningKey]
+	if !ok {
+		return fmt.Errorf("签名密钥 %s 不在密钥列表中", cfg.SigningKey)
+	}
+	if signing.private == nil {
+		return fmt.Errorf("签名密钥 %s 缺少私钥", cfg.SigningKey)
+	}
+	set.signing = signing
+	currentKeys.Store(set)
+
+	global.Logger.Info("JWT密钥加载成功",
+		zap.String("signingKey", signing.id),
+		zap.String("algorithm", signing.method.Alg()),
+		zap.Int("keys", len(set.keys)),
+	)
+	return nil
+}
+
+func loadKey(kc config.JWTKeyConfig) (*signingKey, error) {
+	key := &signingKey{id: kc.ID}
+	switch kc.Algorithm {
+	case "HS256":
+		if kc.Secret == "" {
+			return nil, errors.New("HS256 需要配置 secret")
+		}
+		if len(kc.Secret) < 32 {
+			global.Logger.Warn("HS256 密钥长度不足32字节，容易被暴力破解", zap.String("kid", kc.ID))
+		}
+		key.method = jwt.SigningMethodHS256
+		key.private = []byte(kc.Secret)
+		key.public = key.private
+		return key, nil
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("不支持的算法: %s", kc.Algorithm)
+	}
+
+	if kc.PrivateKeyFile != "" {
+		private, err := readPrivateKey(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("私钥类型不支持签名")
+		}
+		key.private = private
+		key.public = signer.Public()
+	}
+	if kc.PublicKeyFile != "" {
+		public, err := readPublicKey(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.public = public
+	}
+	if key.public == nil {
+		return nil, errors.New("需要配置 private_key_file 或 public_key_file")
+	}
+
+	// 密钥类型必须与声明的算法一致，防止算法混淆
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		if kc.Algorithm != "RS256" {
+			return nil, errors.New("RSA 密钥只能用于 RS256")
+		}
+	case ed25519.PublicKey:
+		if kc.Algorithm != "EdDSA" {
+			return nil, errors.New("Ed25519 密钥只能用于 EdDSA")
+		}
+	default:
+		return nil, errors.New("不支持的密钥类型")
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 不是有效的PEM文件", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (interface{}, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func readPublicKey(path string) (interface{}, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
+
+func loadedKeys() (*keySet, error) {
+	set := currentKeys.Load()
+	if set == nil {
+		return nil, errors.New("JWT密钥未初始化")
+	}
+	return set, nil
+}
+
+// signClaims 使用当前签名密钥签发token，并在头部写入 kid
+func signClaims(claims Claims) (string, error) {
+	set, err := loadedKeys()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(set.signing.method, claims)
+	token.Header["kid"] = set.signing.id
+	return token.SignedString(set.signing.private)
+}
+
+// verifyKey 按 kid 选择验证密钥，并要求token的算法与密钥的算法一致
+func verifyKey(token *jwt.Token) (interface{}, error) {
+	set, err := loadedKeys()
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKeyID
+	}
+	key, ok := set.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("未知的密钥: %s", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("token签名算法与密钥不匹配")
+	}
+	return key.public, nil
+}
+
+// validMethods 当前允许的签名算法
+func validMethods() []string {
+	set := currentKeys.Load()
+	if set == nil {
+		return nil
+	}
+	return set.methods
+}
+
+// JWK JSON Web Key，只包含公钥部分
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS 导出所有非对称密钥的公钥，供其他服务离线校验token。
+// HS256 是对称密钥，不能公开，因此不会出现在结果中
+func PublicJWKS() *JWKS {
+	jwks := &JWKS{Keys: []JWK{}}
+	set := currentKeys.Load()
+	if set == nil {
+		return jwks
+	}
+	for _, key := range set.keys {
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+	return jwks
+}
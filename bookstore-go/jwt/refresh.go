@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"bookstore-manager/global"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrRefreshTokenInvalid 刷新token无效、已过期或已被撤销
+	ErrRefreshTokenInvalid = errors.New("刷新token无效或已过期")
+	// ErrRefreshTokenReused 已经轮换掉的刷新token被再次使用，视为token泄露
+	ErrRefreshTokenReused = errors.New("检测到刷新token被重复使用，已强制下线")
+)
+
+// consumeRefreshScript 原子地校验并消费刷新token：
+// 与会话当前的 refresh_token 一致时清空该字段、记为已使用并返回 1；
+// 不一致但曾经被使用过返回 2（重放）；其他情况返回 0
+var consumeRefreshScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'refresh_token')
+if current and current == ARGV[1] then
+	redis.call('HSET', KEYS[1], 'refresh_token', '')
+	redis.call('SET', KEYS[2], ARGV[3], 'EX', ARGV[2])
+	return 1
+end
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 2
+end
+return 0
+`)
+
+// RefreshTokenPair 用刷新token在原会话上换取新的token对。旧的刷新token立即失效（轮换），
+// 如果一个已经被轮换掉的刷新token再次出现，说明它可能已泄露，撤销它所属的整个会话
+func RefreshTokenPair(refreshToken string, meta *SessionMeta) (*TokenResponse, *Claims, error) {
+	claims, err := parseClaims(refreshToken)
+	if err != nil || claims.TokenType != "refresh" || claims.SessionID == "" {
+		return nil, nil, ErrRefreshTokenInvalid
+	}
+
+	ctx := context.Background()
+	key := sessionKey(claims.UserID, claims.SessionID)
+	usedKey := usedRefreshKey(refreshToken)
+	// 已使用标记保留到该刷新token本身过期为止
+	ttl := int64(time.Until(claims.ExpiresAt.Time).Seconds()) + 1
+
+	result, err := consumeRefreshScript.Run(ctx, global.RedisClient,
+		[]string{key, usedKey}, refreshToken, ttl, claims.SessionID).Int()
+	if err != nil {
+		return nil, nil, err
+	}
+	switch result {
+	case 1:
+		token, err := rotateTokenPair(claims, meta)
+		if err != nil {
+			return nil, nil, err
+		}
+		return token, claims, nil
+	case 2:
+		if err := RevokeSession(claims.UserID, claims.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	default:
+		return nil, nil, ErrRefreshTokenInvalid
+	}
+}
+
+// usedRefreshKey 已使用的刷新token只保存哈希，避免在Redis中留下可用的token原文
+func usedRefreshKey(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return "refresh_used:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,116 @@
+package jwt
+
+import (
+	"bookstore-manager/utils/testenv"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testUserID 每个测试使用独立的用户ID，结束时删除它的 token 数据
+func testUserID(t *testing.T) uint {
+	t.Helper()
+	userID := uint(time.Now().UnixNano() % 1e9)
+	t.Cleanup(func() { RevokeToken(userID) })
+	return userID
+}
+
+func TestRefreshTokenPairRotates(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+	userID := testUserID(t)
+
+	first, err := GenerateTokenPair(userID, "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	second, claims, err := RefreshTokenPair(first.RefreshToken, nil)
+	if err != nil {
+		t.Fatalf("RefreshTokenPair: %v", err)
+	}
+	t.Cleanup(func() { client.Del(ctx, usedRefreshKey(first.RefreshToken)) })
+	if claims.UserID != userID || claims.Role != RoleUser {
+		t.Errorf("claims = %+v, want user %d role %s", claims, userID, RoleUser)
+	}
+	newClaims, err := ParseToken(second.AccessToken)
+	if err != nil {
+		t.Fatalf("new access token rejected: %v", err)
+	}
+	if newClaims.SessionID != claims.SessionID {
+		t.Errorf("session id changed on rotation: %s -> %s", claims.SessionID, newClaims.SessionID)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, err := ParseToken(first.AccessToken); err == nil {
+		t.Error("old access token is still valid after rotation")
+	}
+	if client.Exists(ctx, usedRefreshKey(first.RefreshToken)).Val() != 1 {
+		t.Error("consumed refresh token was not marked as used")
+	}
+}
+
+func TestRefreshTokenPairReuseRevokesSession(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+	userID := testUserID(t)
+
+	first, err := GenerateTokenPair(userID, "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	t.Cleanup(func() { client.Del(ctx, usedRefreshKey(first.RefreshToken)) })
+	otherDevice, err := GenerateTokenPair(userID, "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	second, claims, err := RefreshTokenPair(first.RefreshToken, nil)
+	if err != nil {
+		t.Fatalf("RefreshTokenPair: %v", err)
+	}
+
+	// 攻击者重放已经轮换掉的刷新token
+	if _, _, err := RefreshTokenPair(first.RefreshToken, nil); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replay err = %v, want ErrRefreshTokenReused", err)
+	}
+	if client.Exists(ctx, sessionKey(userID, claims.SessionID)).Val() != 0 {
+		t.Error("session still exists after refresh token reuse")
+	}
+	if _, err := ParseToken(second.AccessToken); err == nil {
+		t.Error("access token issued by rotation is still valid after reuse")
+	}
+	if _, _, err := RefreshTokenPair(second.RefreshToken, nil); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("refresh with rotated token after revoke: err = %v, want ErrRefreshTokenInvalid", err)
+	}
+	// 只撤销泄露的会话，其他设备不受影响
+	if _, err := ParseToken(otherDevice.AccessToken); err != nil {
+		t.Errorf("other session revoked: %v", err)
+	}
+}
+
+func TestRefreshTokenPairRejectsAccessToken(t *testing.T) {
+	testenv.Redis(t)
+	userID := testUserID(t)
+
+	pair, err := GenerateTokenPair(userID, "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	if _, _, err := RefreshTokenPair(pair.AccessToken, nil); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("err = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if _, err := ParseToken(pair.AccessToken); err != nil {
+		t.Errorf("session revoked by a rejected refresh attempt: %v", err)
+	}
+}
+
+func TestUsedRefreshKeyDoesNotContainToken(t *testing.T) {
+	key := usedRefreshKey("header.payload.signature")
+	if key == usedRefreshKey("header.payload.other") {
+		t.Error("different tokens map to the same key")
+	}
+	if len(key) != len("refresh_used:")+64 {
+		t.Errorf("unexpected key %q", key)
+	}
+}
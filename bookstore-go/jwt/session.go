@@ -0,0 +1,210 @@
+package jwt
+
+import (
+	"bookstore-manager/global"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSessionNotFound 会话不存在或已过期
+var ErrSessionNotFound = errors.New("会话不存在或已失效")
+
+// sessionTouchInterval 最后活跃时间的更新间隔，避免每个请求都写Redis
+const sessionTouchInterval = time.Minute
+
+// SessionMeta 登录时记录的设备信息
+type SessionMeta struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
+
+// Session 一个登录设备上的会话
+type Session struct {
+	ID         string `json:"id"`
+	DeviceName string `json:"device_name"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	Role       string `json:"role"`
+	CreatedAt  int64  `json:"created_at"`
+	LastSeen   int64  `json:"last_seen"`
+	Current    bool   `json:"current"` //是否为发起请求的会话
+}
+
+// user_tokens:<用户id>:<会话id> 保存单个会话的token和设备信息，
+// user_sessions:<用户id> 是该用户所有会话id的集合
+func sessionKey(userID uint, sessionID string) string {
+	return fmt.Sprintf("user_tokens:%d:%s", userID, sessionID)
+}
+
+func sessionIndexKey(userID uint) string {
+	return fmt.Sprintf("user_sessions:%d", userID)
+}
+
+// updateSessionScript 会话仍存在时才写入新的token，防止刷新过程中会话被撤销后又被写回
+var updateSessionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'access_token', ARGV[1], 'refresh_token', ARGV[2], 'last_seen', ARGV[3])
+if ARGV[4] ~= '' then
+	redis.call('HSET', KEYS[1], 'ip', ARGV[4])
+end
+if ARGV[5] ~= '' then
+	redis.call('HSET', KEYS[1], 'user_agent', ARGV[5])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[6])
+redis.call('EXPIRE', KEYS[2], ARGV[6])
+return 1
+`)
+
+// rotateTokenPair 在原会话上重新签发token对，会话id保持不变
+func rotateTokenPair(claims *Claims, meta *SessionMeta) (*TokenResponse, error) {
+	token, err := signTokenPair(claims.UserID, claims.Username, claims.Role, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = &SessionMeta{}
+	}
+	ok, err := updateSessionScript.Run(context.Background(), global.RedisClient,
+		[]string{sessionKey(claims.UserID, claims.SessionID), sessionIndexKey(claims.UserID)},
+		token.AccessToken, token.RefreshToken, time.Now().Unix(),
+		meta.IP, meta.UserAgent, int64(RefreshTokenExpire.Seconds())).Int()
+	if err != nil {
+		return nil, err
+	}
+	if ok == 0 {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return token, nil
+}
+
+// touchSessionScript 会话仍存在且距上次更新超过间隔时才写入最后活跃时间，
+// 避免读写之间会话被撤销后又被 HSET 重新创建出来
+var touchSessionScript = redis.NewScript(`
+local lastSeen = redis.call('HGET', KEYS[1], 'last_seen')
+if not lastSeen then
+	return 0
+end
+if tonumber(ARGV[1]) - tonumber(lastSeen) < tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'last_seen', ARGV[1])
+return 1
+`)
+
+// touchSession 更新会话的最后活跃时间，失败不影响请求
+func touchSession(userID uint, sessionID string) {
+	touchSessionScript.Run(context.Background(), global.RedisClient,
+		[]string{sessionKey(userID, sessionID)},
+		time.Now().Unix(), int64(sessionTouchInterval.Seconds()))
+}
+
+// ListSessions 列出用户所有有效会话，按最后活跃时间倒序；
+// 已过期的会话会顺带从会话集合中清理掉
+func ListSessions(userID uint, currentSessionID string) ([]*Session, error) {
+	ctx := context.Background()
+	indexKey := sessionIndexKey(userID)
+	sessionIDs, err := global.RedisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := global.RedisClient.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		cmds[i] = pipe.HMGet(ctx, sessionKey(userID, sessionID),
+			"device", "ip", "user_agent", "role", "created_at", "last_seen")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(sessionIDs))
+	var expired []interface{}
+	for i, sessionID := range sessionIDs {
+		values := cmds[i].Val()
+		if len(values) != 6 || values[4] == nil {
+			expired = append(expired, sessionID)
+			continue
+		}
+		sessions = append(sessions, &Session{
+			ID:         sessionID,
+			DeviceName: stringValue(values[0]),
+			IP:         stringValue(values[1]),
+			UserAgent:  stringValue(values[2]),
+			Role:       stringValue(values[3]),
+			CreatedAt:  int64Value(values[4]),
+			LastSeen:   int64Value(values[5]),
+			Current:    sessionID == currentSessionID,
+		})
+	}
+	if len(expired) > 0 {
+		global.RedisClient.SRem(ctx, indexKey, expired...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen > sessions[j].LastSeen
+	})
+	return sessions, nil
+}
+
+// RevokeSession 撤销单个会话（退出某一台设备）
+func RevokeSession(userID uint, sessionID string) error {
+	ctx := context.Background()
+	deleted, err := global.RedisClient.Del(ctx, sessionKey(userID, sessionID)).Result()
+	if err != nil {
+		return err
+	}
+	global.RedisClient.SRem(ctx, sessionIndexKey(userID), sessionID)
+	if deleted == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions 撤销除 keepSessionID 以外的所有会话，返回撤销的数量
+func RevokeOtherSessions(userID uint, keepSessionID string) (int, error) {
+	ctx := context.Background()
+	indexKey := sessionIndexKey(userID)
+	sessionIDs, err := global.RedisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	var members []interface{}
+	for _, sessionID := range sessionIDs {
+		if sessionID == keepSessionID {
+			continue
+		}
+		keys = append(keys, sessionKey(userID, sessionID))
+		members = append(members, sessionID)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	pipe := global.RedisClient.TxPipeline()
+	deleted := pipe.Del(ctx, keys...)
+	pipe.SRem(ctx, indexKey, members...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(deleted.Val()), nil
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func int64Value(v interface{}) int64 {
+	n, _ := strconv.ParseInt(stringValue(v), 10, 64)
+	return n
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"bookstore-manager/utils/testenv"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionScriptsDoNotRecreateRevokedSession(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+	userID := testUserID(t)
+
+	pair, err := GenerateTokenPair(userID, "alice", RoleUser, &SessionMeta{DeviceName: "phone"})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	claims, err := ParseToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if err := RevokeSession(userID, claims.SessionID); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	key := sessionKey(userID, claims.SessionID)
+	touchSessionScript.Run(ctx, client, []string{key}, time.Now().Unix()+int64(sessionTouchInterval.Seconds()), int64(sessionTouchInterval.Seconds()))
+	if client.Exists(ctx, key).Val() != 0 {
+		t.Error("touch recreated a revoked session")
+	}
+	if _, err := rotateTokenPair(claims, nil); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("rotate on revoked session: err = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if client.Exists(ctx, key).Val() != 0 {
+		t.Error("rotation recreated a revoked session")
+	}
+}
+
+func TestTouchSessionScript(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+	interval := int64(sessionTouchInterval.Seconds())
+
+	tests := []struct {
+		name     string
+		lastSeen int64
+		now      int64
+		want     int64
+	}{
+		{"within interval", 1000, 1000 + interval - 1, 1000},
+		{"after interval", 1000, 1000 + interval, 1000 + interval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := sessionKey(testUserID(t), "s1")
+			t.Cleanup(func() { client.Del(ctx, key) })
+			client.HSet(ctx, key, "last_seen", tt.lastSeen)
+			if err := touchSessionScript.Run(ctx, client, []string{key}, tt.now, interval).Err(); err != nil {
+				t.Fatalf("script error: %v", err)
+			}
+			if got, _ := client.HGet(ctx, key, "last_seen").Int64(); got != tt.want {
+				t.Errorf("last_seen = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeOtherSessionsKeepsCurrent(t *testing.T) {
+	testenv.Redis(t)
+	userID := testUserID(t)
+
+	current, err := GenerateTokenPair(userID, "alice", RoleUser, &SessionMeta{DeviceName: "laptop"})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	other, err := GenerateTokenPair(userID, "alice", RoleUser, &SessionMeta{DeviceName: "phone"})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	claims, err := ParseToken(current.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+
+	n, err := RevokeOtherSessions(userID, claims.SessionID)
+	if err != nil || n != 1 {
+		t.Fatalf("RevokeOtherSessions = %d, %v, want 1, nil", n, err)
+	}
+	if _, err := ParseToken(other.AccessToken); err == nil {
+		t.Error("other session is still valid")
+	}
+	sessions, err := ListSessions(userID, claims.SessionID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 1 || !sessions[0].Current || sessions[0].DeviceName != "laptop" {
+		t.Errorf("sessions = %+v, want only the current laptop session", sessions)
+	}
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"bookstore-manager/global"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LogMailer 不真正发信，只把邮件记录到日志，配置了文件时同时追加写入文件，
+// 开发和测试环境可以从文件里拿到重置密码、验证邮箱的链接
+type LogMailer struct {
+	file string
+	mu   sync.Mutex
+}
+
+func NewLogMailer(file string) *LogMailer {
+	return &LogMailer{file: file}
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	global.Logger.Info("【模拟发送邮件】",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+	)
+	if m.file == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(m.file), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(m.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "===== %s =====\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.DateTime), msg.To, msg.Subject, msg.Body)
+	return err
+}
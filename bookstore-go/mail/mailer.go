@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Message 一封待发送的邮件，Body 为纯文本
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口，业务代码只依赖这个接口，具体实现由配置决定
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// Default 全局邮件发送器，在 main.go 中通过 InitMailer 初始化
+var Default Mailer
+
+// InitMailer 根据配置选择邮件实现
+func InitMailer() error {
+	cfg := config.AppConfig.Mail
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.Host == "" || cfg.From == "" {
+			return fmt.Errorf("smtp 邮件需要配置 host 和 from")
+		}
+		Default = NewSMTPMailer(cfg)
+	case "log", "":
+		Default = NewLogMailer(cfg.LogFile)
+	default:
+		return fmt.Errorf("不支持的邮件驱动: %s", cfg.Driver)
+	}
+	global.Logger.Info("邮件服务初始化成功", zap.String("driver", cfg.Driver))
+	return nil
+}
+
+// Send 使用全局邮件发送器发送邮件
+func Send(msg *Message) error {
+	if Default == nil {
+		return fmt.Errorf("邮件服务未初始化")
+	}
+	return Default.Send(msg)
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// DataMigration 已执行过的数据迁移，每个迁移只执行一次
+type DataMigration struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+func (m *DataMigration) TableName() string {
+	return "data_migrations"
+}
@@ -4,19 +4,98 @@ import (
 	"time"
 )
 
+// 订单状态。0/1/2 沿用旧版本的取值，已有数据无需迁移
+const (
+	OrderStatusPending   = 0 // 待支付
+	OrderStatusPaid      = 1 // 已支付
+	OrderStatusCancelled = 2 // 已取消
+	OrderStatusPacked    = 3 // 已打包
+	OrderStatusShipped   = 4 // 已发货
+	OrderStatusDelivered = 5 // 已送达
+	OrderStatusCompleted = 6 // 已完成
+	OrderStatusRefunding = 7 // 退款中
+	OrderStatusRefunded  = 8 // 已退款
+)
+
+// 状态变更的操作者类型
+const (
+	OrderActorUser   = "user"
+	OrderActorAdmin  = "admin"
+	OrderActorSystem = "system"
+)
+
+var orderStatusNames = map[int]string{
+	OrderStatusPending:   "pending",
+	OrderStatusPaid:      "paid",
+	OrderStatusCancelled: "cancelled",
+	OrderStatusPacked:    "packed",
+	OrderStatusShipped:   "shipped",
+	OrderStatusDelivered: "delivered",
+	OrderStatusCompleted: "completed",
+	OrderStatusRefunding: "refunding",
+	OrderStatusRefunded:  "refunded",
+}
+
+// orderTransitions 允许的状态流转，不在表中的变更一律拒绝。
+// 退款中的订单在退款被驳回或部分退款后回到申请退款之前的状态
+var orderTransitions = map[int][]int{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusPacked, OrderStatusRefunding},
+	OrderStatusPacked:    {OrderStatusShipped, OrderStatusRefunding},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunding},
+	OrderStatusDelivered: {OrderStatusCompleted, OrderStatusRefunding},
+	OrderStatusCompleted: {OrderStatusRefunding},
+	OrderStatusRefunding: {OrderStatusRefunded, OrderStatusPaid, OrderStatusPacked, OrderStatusShipped, OrderStatusDelivered, OrderStatusCompleted},
+}
+
+// OrderStatusName 状态的英文名，未知状态返回 "unknown"
+func OrderStatusName(status int) string {
+	if name, ok := orderStatusNames[status]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseOrderStatus 按英文名查找状态
+func ParseOrderStatus(name string) (int, bool) {
+	for status, n := range orderStatusNames {
+		if n == name {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+// CanTransitOrder 订单能否从 from 变更到 to
+func CanTransitOrder(from, to int) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	BaseModel
 
 	UserID      int64      `json:"user_id,string"`
-	OrderNo     string     `json:"order_no"`
+	OrderNo     string     `gorm:"type:varchar(32);uniqueIndex" json:"order_no"` // 日期+渠道+雪花ID+校验位，见 utils/orderno
 	TotalAmount int        `json:"total_amount"`
 	Status      int        `json:"status"`
 	IsPaid      bool       `json:"is_paid"`
 	PaymentTime *time.Time `json:"payment_time"`
+	// StockReserved 下单时是否已预占库存。之前的版本在支付时才扣库存，
+	// 这类历史订单支付时仍需扣减，取消时不能归还
+	StockReserved bool `gorm:"default:false" json:"-"`
+	// CampaignID 秒杀订单对应的活动，普通订单为 0
+	CampaignID int64 `gorm:"not null;default:0;index" json:"campaign_id,string"`
 
 	// 关联字段
-	User       *User       `gorm:"foreignKey:UserID" json:"user"`
-	OrderItems []OrderItem `gorm:"foreignKey:OrderID" json:"order_items"`
+	User          *User                `gorm:"foreignKey:UserID" json:"user"`
+	OrderItems    []OrderItem          `gorm:"foreignKey:OrderID" json:"order_items"`
+	StatusHistory []OrderStatusHistory `gorm:"foreignKey:OrderID" json:"status_history,omitempty"` // 只在订单详情中加载
+	Refunds       []Refund             `gorm:"foreignKey:OrderID" json:"refunds,omitempty"`        // 只在订单详情中加载
 }
 
 func (o *Order) TableName() string {
@@ -26,11 +105,15 @@ func (o *Order) TableName() string {
 type OrderItem struct {
 	BaseModel
 
-	OrderID  int64 `gorm:"not null" json:"order_id,string"`
-	BookID   int64 `gorm:"not null" json:"book_id,string"`
-	Quantity int   `gorm:"not null" json:"quantity"`
-	Price    int   `gorm:"not null" json:"price"`
-	Subtotal int   `gorm:"not null" json:"subtotal"`
+	OrderID   int64 `gorm:"not null" json:"order_id,string"`
+	BookID    int64 `gorm:"not null" json:"book_id,string"`
+	Quantity  int   `gorm:"not null" json:"quantity"`
+	ListPrice int   `gorm:"not null;default:0" json:"list_price"` // 下单时的图书原价
+	Discount  int   `gorm:"not null;default:0" json:"discount"`   // 下单时的折扣百分比，0表示无折扣
+	Price     int   `gorm:"not null" json:"price"`                // 折后成交单价
+	Subtotal  int   `gorm:"not null" json:"subtotal"`
+	// RefundedQuantity 已退款的数量，不能超过 Quantity
+	RefundedQuantity int `gorm:"not null;default:0" json:"refunded_quantity"`
 
 	Book *Book `gorm:"foreignKey:BookID" json:"book"`
 }
@@ -38,3 +121,19 @@ type OrderItem struct {
 func (oi *OrderItem) TableName() string {
 	return "order_items"
 }
+
+// OrderStatusHistory 订单状态变更记录，每次流转写入一条
+type OrderStatusHistory struct {
+	BaseModel
+
+	OrderID    int64  `gorm:"not null;index" json:"order_id,string"`
+	FromStatus int    `gorm:"not null" json:"from_status"`
+	ToStatus   int    `gorm:"not null" json:"to_status"`
+	ActorType  string `gorm:"type:varchar(16);not null" json:"actor_type"` // user、admin 或 system
+	ActorID    int64  `json:"actor_id,string"`                             // 系统操作时为 0
+	Reason     string `gorm:"type:varchar(255)" json:"reason"`
+}
+
+func (h *OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
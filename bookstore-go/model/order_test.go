@@ -0,0 +1,36 @@
+package model
+
+import "testing"
+
+func TestCanTransitOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		want     bool
+	}{
+		{"pending to paid", OrderStatusPending, OrderStatusPaid, true},
+		{"pending to cancelled", OrderStatusPending, OrderStatusCancelled, true},
+		{"pending to shipped", OrderStatusPending, OrderStatusShipped, false},
+		{"paid to packed", OrderStatusPaid, OrderStatusPacked, true},
+		{"paid to cancelled", OrderStatusPaid, OrderStatusCancelled, false},
+		{"packed to shipped", OrderStatusPacked, OrderStatusShipped, true},
+		{"shipped to delivered", OrderStatusShipped, OrderStatusDelivered, true},
+		{"shipped to packed", OrderStatusShipped, OrderStatusPacked, false},
+		{"delivered to completed", OrderStatusDelivered, OrderStatusCompleted, true},
+		{"completed to refunding", OrderStatusCompleted, OrderStatusRefunding, true},
+		{"refunding to refunded", OrderStatusRefunding, OrderStatusRefunded, true},
+		{"refunding back to shipped", OrderStatusRefunding, OrderStatusShipped, true},
+		{"refunding to cancelled", OrderStatusRefunding, OrderStatusCancelled, false},
+		{"cancelled is final", OrderStatusCancelled, OrderStatusPaid, false},
+		{"refunded is final", OrderStatusRefunded, OrderStatusRefunding, false},
+		{"same status", OrderStatusPaid, OrderStatusPaid, false},
+		{"unknown status", 99, OrderStatusPaid, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanTransitOrder(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransitOrder(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
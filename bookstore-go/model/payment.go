@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// 支付单状态
+const (
+	PaymentStatusPending   = "pending"   // 等待用户付款
+	PaymentStatusSucceeded = "succeeded" // 渠道确认付款成功
+	PaymentStatusFailed    = "failed"    // 付款失败
+	PaymentStatusRefunded  = "refunded"  // 已全额退款
+)
+
+// Payment 支付单，订单的已支付状态只由渠道回调（或主动查询）确认的支付单驱动
+type Payment struct {
+	BaseModel
+
+	PaymentNo      string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"payment_no"`
+	OrderID        int64      `gorm:"not null;index" json:"order_id,string"`
+	UserID         int64      `gorm:"not null;index" json:"user_id,string"`
+	Provider       string     `gorm:"type:varchar(32);not null" json:"provider"`
+	Amount         int        `gorm:"not null" json:"amount"`
+	RefundedAmount int        `gorm:"not null;default:0" json:"refunded_amount"` // 已退款金额，全部退完后状态变为 refunded
+	Status         string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	TradeNo        string     `gorm:"type:varchar(64)" json:"trade_no"` // 渠道流水号
+	PayURL         string     `gorm:"type:varchar(255)" json:"pay_url"`
+	PaidAt         *time.Time `json:"paid_at"`
+}
+
+func (p *Payment) TableName() string {
+	return "payments"
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// 退款单状态
+const (
+	RefundStatusPending  = "pending"  // 待审核
+	RefundStatusApproved = "approved" // 已同意，等待支付渠道退款到账
+	RefundStatusRejected = "rejected" // 已驳回
+	RefundStatusRefunded = "refunded" // 退款成功
+	RefundStatusFailed   = "failed"   // 渠道退款失败，可重新审核通过以重试
+)
+
+// Refund 退款/退货申请。部分退款按订单项和数量申请，金额按下单时的成交单价计算
+type Refund struct {
+	BaseModel
+
+	RefundNo    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"refund_no"`
+	OrderID     int64      `gorm:"not null;index" json:"order_id,string"`
+	UserID      int64      `gorm:"not null;index" json:"user_id,string"`
+	Amount      int        `gorm:"not null" json:"amount"`
+	Reason      string     `gorm:"type:varchar(255)" json:"reason"`
+	Status      string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	PrevStatus  int        `gorm:"not null" json:"-"` // 申请前的订单状态，驳回或部分退款后恢复
+	AdminID     int64      `json:"admin_id,string"`
+	AdminRemark string     `gorm:"type:varchar(255)" json:"admin_remark"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	RefundedAt  *time.Time `json:"refunded_at"`
+
+	Items []RefundItem `gorm:"foreignKey:RefundID" json:"items"`
+}
+
+func (r *Refund) TableName() string {
+	return "refunds"
+}
+
+// RefundItem 退款单中的一项
+type RefundItem struct {
+	BaseModel
+
+	RefundID    int64 `gorm:"not null;index" json:"refund_id,string"`
+	OrderItemID int64 `gorm:"not null" json:"order_item_id,string"`
+	BookID      int64 `gorm:"not null" json:"book_id,string"`
+	Quantity    int   `gorm:"not null" json:"quantity"`
+	Amount      int   `gorm:"not null" json:"amount"`
+}
+
+func (ri *RefundItem) TableName() string {
+	return "refund_items"
+}
@@ -0,0 +1,53 @@
+package model
+
+// 权限编码，格式为 资源:操作
+const (
+	PermDashboardView = "dashboard:view"
+	PermBookView      = "book:view"
+	PermBookEdit      = "book:edit"
+	PermCategoryView  = "category:view"
+	PermCategoryEdit  = "category:edit"
+	PermUserView      = "user:view"
+	PermUserEdit      = "user:edit"
+	PermOrderView     = "order:view"
+	PermOrderCancel   = "order:cancel"
+	PermOrderFulfil   = "order:fulfil"
+	PermRefundManage  = "refund:manage"
+	PermSeckillManage = "seckill:manage"
+	PermRoleManage    = "role:manage"
+)
+
+// 内置角色编码
+const (
+	RoleSuperAdmin      = "super_admin" // 超级管理员，拥有所有权限
+	RoleCatalogEditor   = "catalog_editor"
+	RoleCustomerSupport = "customer_support"
+	RoleFinance         = "finance"
+)
+
+type Permission struct {
+	BaseModel
+
+	Code        string `gorm:"type:varchar(64);unique;not null" json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (p *Permission) TableName() string {
+	return "permissions"
+}
+
+type Role struct {
+	BaseModel
+
+	Code        string `gorm:"type:varchar(64);unique;not null" json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsSystem    bool   `gorm:"default:false" json:"is_system"` //内置角色不允许删除
+
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
+}
+
+func (r *Role) TableName() string {
+	return "roles"
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// SeckillCampaign 秒杀活动，同一本书在活动时间内以秒杀价限量销售。
+// 活动库存 Quantity 从图书库存中划出，下单时仍会预占图书库存
+type SeckillCampaign struct {
+	BaseModel
+
+	Name         string    `gorm:"type:varchar(100);not null" json:"name"`
+	BookID       int64     `gorm:"not null;index" json:"book_id,string"`
+	SalePrice    int       `gorm:"not null" json:"sale_price"`               // 秒杀价（元）
+	Quantity     int       `gorm:"not null" json:"quantity"`                 // 活动总库存
+	PerUserLimit int       `gorm:"not null;default:1" json:"per_user_limit"` // 每个用户最多购买的数量
+	StartTime    time.Time `gorm:"not null" json:"start_time"`
+	EndTime      time.Time `gorm:"not null" json:"end_time"`
+	Status       int       `gorm:"not null;default:1" json:"status"` // 1-启用，0-停用
+
+	Book *Book `gorm:"foreignKey:BookID" json:"book,omitempty"`
+}
+
+func (s *SeckillCampaign) TableName() string {
+	return "seckill_campaigns"
+}
@@ -1,14 +1,32 @@
 package model
 
+import "time"
+
+const (
+	UserStatusDisabled = 0 // 已禁用
+	UserStatusActive   = 1 // 正常
+)
+
 type User struct {
 	BaseModel // 嵌入 BaseModel，自动获得 ID, CreatedAt, UpdatedAt
 
 	Username string `gorm:"type:varchar(191);unique;not null" json:"username"`
-	Password string `json:"password"`
+	Password string `json:"-"`
 	Email    string `gorm:"type:varchar(191);unique;not null" json:"email"`
 	Phone    string `json:"phone"`
 	Avatar   string `json:"avatar"` //头像
 	IsAdmin  bool   `gorm:"default:false" json:"is_admin"`
+	Status   int    `gorm:"default:1" json:"status"` //账号状态：0-禁用，1-正常
+
+	EmailVerified   bool       `gorm:"default:false" json:"email_verified"` //邮箱是否已验证，修改邮箱后需要重新验证
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	TwoFactorEnabled bool   `gorm:"default:false" json:"two_factor_enabled"` //是否开启两步验证
+	TwoFactorSecret  string `gorm:"type:varchar(64)" json:"-"`               //TOTP密钥(base32)
+	RecoveryCodes    string `gorm:"type:text" json:"-"`                      //未使用的恢复码哈希，逗号分隔
+
+	// IsAdmin 决定能否登录后台，Roles 决定登录后能访问哪些后台功能
+	Roles []Role `gorm:"many2many:user_roles" json:"roles,omitempty"`
 }
 
 func (u *User) TableName() string {
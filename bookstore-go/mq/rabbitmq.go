@@ -4,6 +4,9 @@ import (
 	"bookstore-manager/config"
 	"bookstore-manager/global"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.uber.org/zap"
@@ -12,6 +15,13 @@ import (
 var Conn *amqp.Connection
 var Channel *amqp.Channel
 
+const (
+	// OrderDelayQueue 订单超时延迟队列，没有消费者，消息过期后转发到 OrderTimeoutKey
+	OrderDelayQueue = "order_delay_queue"
+	// OrderTimeoutKey 订单支付超时的 routing key
+	OrderTimeoutKey = "order.timeout"
+)
+
 // InitRabbitMQ 初始化连接 (在 main.go 中调用)
 func InitRabbitMQ() {
 	cfg := config.AppConfig.RabbitMQ
@@ -64,6 +74,17 @@ func InitRabbitMQ() {
 		global.Logger.Fatal("声明交换机失败", zap.Error(err))
 	}
 
+	// 4. 声明订单超时延迟队列
+	// 消息按各自的 expiration 过期后死信到业务交换机，routing key 改为 order.timeout；
+	// 不复用 dlx_exchange，否则超时消息也会被 "#" 绑定投递到 dlq_queue
+	_, err = Channel.QueueDeclare(OrderDelayQueue, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "bookstore_event_exchange",
+		"x-dead-letter-routing-key": OrderTimeoutKey,
+	})
+	if err != nil {
+		global.Logger.Fatal("声明延迟队列失败", zap.Error(err))
+	}
+
 	global.Logger.Info("RabbitMQ 初始化成功")
 }
 
@@ -86,7 +107,29 @@ func SendMessage(routingKey string, message string) error {
 	return nil
 }
 
-// StartConsumer 监听指定 routing key 的消息
+// SendDelayedMessage 发送延迟消息，delay 之后以 OrderTimeoutKey 投递给消费者。
+// 队列只在头部检查过期，所有消息应使用相同的延迟，否则较短的会被前面较长的挡住
+func SendDelayedMessage(message string, delay time.Duration) error {
+	err := Channel.Publish(
+		"",              // 默认交换机，直接投递到延迟队列
+		OrderDelayQueue, // routing key 即队列名
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			Expiration:   strconv.FormatInt(delay.Milliseconds(), 10),
+			Body:         []byte(message),
+		})
+	if err != nil {
+		global.Logger.Error("发送延迟消息失败", zap.Duration("delay", delay), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// StartConsumer 监听指定 routing key 的消息，每个 routing key 使用独立的队列
+// （order.seckill -> order_seckill_queue），避免不同消费者互相抢消息
 func StartConsumer(routingKey string, handler func(string, amqp.Delivery)) {
 	// 配置队列参数，绑定死信
 	args := amqp.Table{
@@ -94,12 +137,12 @@ func StartConsumer(routingKey string, handler func(string, amqp.Delivery)) {
 		"x-dead-letter-exchange": "dlx_exchange",
 	}
 	q, err := Channel.QueueDeclare(
-		"order_seckill_queue", // 队列名称
-		true,                  // durable
-		false,                 // delete when unused
-		false,                 // exclusive
-		false,                 // no-wait
-		args,                  // arguments
+		strings.ReplaceAll(routingKey, ".", "_")+"_queue", // 队列名称
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		args,  // arguments
 	)
 	if err != nil {
 		global.Logger.Error("声明队列失败", zap.Error(err))
@@ -0,0 +1,279 @@
+package payment
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// MockSignatureHeader 模拟网关回调的签名头，格式 t=<unix秒>,v1=<hex(hmac_sha256(t.body))>
+	MockSignatureHeader = "X-Mock-Signature"
+	// mockSignatureTolerance 回调时间戳与当前时间相差超过这个值视为重放
+	mockSignatureTolerance = 5 * time.Minute
+)
+
+// 模拟收银台支持的结果
+const (
+	MockOutcomeSuccess = "success"
+	MockOutcomeFail    = "fail"
+	MockOutcomeTimeout = "timeout" // 用户付了款但网关一直不回调，只能靠主动查询或订单超时
+)
+
+type mockTrade struct {
+	paymentNo string
+	tradeNo   string
+	amount    int
+	status    string
+	notifyURL string
+	refunded  map[string]int // refundNo -> 金额
+}
+
+// MockProvider 本地模拟的支付网关：创建支付单后由 /payment/mock/:paymentNo 模拟用户付款结果，
+// 然后像真实网关一样延迟发送签名回调。交易只保存在内存中，重启后丢失
+type MockProvider struct {
+	secret    []byte
+	baseURL   string
+	delay     time.Duration
+	client    *http.Client
+	mu        sync.Mutex
+	trades    map[string]*mockTrade
+	tradeSeed int64
+}
+
+func NewMockProvider(cfg config.PaymentConfig) *MockProvider {
+	delay := time.Duration(cfg.Mock.CallbackDelaySeconds) * time.Second
+	if delay <= 0 {
+		delay = 2 * time.Second
+	}
+	return &MockProvider{
+		secret:  []byte(cfg.Mock.Secret),
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		delay:   delay,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		trades:  make(map[string]*mockTrade),
+	}
+}
+
+func (m *MockProvider) Name() string {
+	return "mock"
+}
+
+func (m *MockProvider) CreateIntent(req *IntentRequest) (*Intent, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("支付金额必须大于0")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if trade, ok := m.trades[req.PaymentNo]; ok {
+		return &Intent{TradeNo: trade.tradeNo, PayURL: m.payURL(req.PaymentNo)}, nil
+	}
+	m.tradeSeed++
+	trade := &mockTrade{
+		paymentNo: req.PaymentNo,
+		tradeNo:   fmt.Sprintf("MOCK%d%04d", time.Now().Unix(), m.tradeSeed%10000),
+		amount:    req.Amount,
+		status:    StatusPending,
+		notifyURL: req.NotifyURL,
+		refunded:  make(map[string]int),
+	}
+	m.trades[req.PaymentNo] = trade
+	return &Intent{TradeNo: trade.tradeNo, PayURL: m.payURL(req.PaymentNo)}, nil
+}
+
+func (m *MockProvider) Query(paymentNo string) (*QueryResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trade, ok := m.trades[paymentNo]
+	if !ok {
+		return nil, ErrTradeNotFound
+	}
+	return &QueryResult{
+		PaymentNo: trade.paymentNo,
+		TradeNo:   trade.tradeNo,
+		Status:    trade.status,
+		Amount:    trade.amount,
+	}, nil
+}
+
+// Refund 模拟网关的退款同步完成，同时也会发送退款回调
+func (m *MockProvider) Refund(req *RefundRequest) (*RefundResult, error) {
+	m.mu.Lock()
+	trade, ok := m.trades[req.PaymentNo]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrTradeNotFound
+	}
+	if trade.status != StatusSucceeded {
+		m.mu.Unlock()
+		return nil, errors.New("交易未支付成功，不能退款")
+	}
+	if _, done := trade.refunded[req.RefundNo]; !done {
+		total := 0
+		for _, amount := range trade.refunded {
+			total += amount
+		}
+		if req.Amount <= 0 || total+req.Amount > trade.amount {
+			m.mu.Unlock()
+			return nil, errors.New("退款金额超过可退金额")
+		}
+		trade.refunded[req.RefundNo] = req.Amount
+	}
+	event := &CallbackEvent{
+		Type:      EventRefund,
+		PaymentNo: trade.paymentNo,
+		RefundNo:  req.RefundNo,
+		TradeNo:   trade.tradeNo,
+		Status:    StatusSucceeded,
+		Amount:    trade.refunded[req.RefundNo],
+	}
+	notifyURL := trade.notifyURL
+	m.mu.Unlock()
+
+	m.notifyLater(notifyURL, event)
+	return &RefundResult{RefundNo: req.RefundNo, TradeNo: trade.tradeNo, Status: StatusSucceeded}, nil
+}
+
+func (m *MockProvider) VerifyCallback(header http.Header, body []byte) (*CallbackEvent, error) {
+	var timestamp, signature string
+	for _, part := range strings.Split(header.Get(MockSignatureHeader), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			signature = v
+		}
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return nil, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > mockSignatureTolerance || age < -mockSignatureTolerance {
+		return nil, ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, m.sign(timestamp, body)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event CallbackEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("回调内容格式错误: %w", err)
+	}
+	return &event, nil
+}
+
+// Simulate 模拟用户在收银台的操作结果，success/fail 会在延迟后发送回调，timeout 不发送回调
+func (m *MockProvider) Simulate(paymentNo, outcome string) error {
+	m.mu.Lock()
+	trade, ok := m.trades[paymentNo]
+	if !ok {
+		m.mu.Unlock()
+		return ErrTradeNotFound
+	}
+	if trade.status != StatusPending {
+		m.mu.Unlock()
+		return errors.New("该支付单已完成")
+	}
+	switch outcome {
+	case MockOutcomeSuccess, MockOutcomeTimeout:
+		// 超时场景下钱已经扣了，只是回调丢失，主动查询能查到成功
+		trade.status = StatusSucceeded
+	case MockOutcomeFail:
+		trade.status = StatusFailed
+	default:
+		m.mu.Unlock()
+		return fmt.Errorf("不支持的模拟结果: %s", outcome)
+	}
+	event := &CallbackEvent{
+		Type:      EventPayment,
+		PaymentNo: trade.paymentNo,
+		TradeNo:   trade.tradeNo,
+		Status:    trade.status,
+		Amount:    trade.amount,
+	}
+	notifyURL := trade.notifyURL
+	m.mu.Unlock()
+
+	if outcome != MockOutcomeTimeout {
+		m.notifyLater(notifyURL, event)
+	}
+	return nil
+}
+
+// SignedRequest 生成带签名的回调请求体和签名头，也可用于本地手工调试回调
+func (m *MockProvider) SignedRequest(event *CallbackEvent) ([]byte, string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return body, fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(m.sign(timestamp, body))), nil
+}
+
+func (m *MockProvider) sign(timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func (m *MockProvider) payURL(paymentNo string) string {
+	return m.baseURL + "/api/v1/payment/mock/" + paymentNo
+}
+
+// notifyLater 延迟发送回调，失败时按 1、2、4 倍延迟重试几次，模拟真实网关的重复通知
+func (m *MockProvider) notifyLater(notifyURL string, event *CallbackEvent) {
+	if notifyURL == "" {
+		return
+	}
+	go func() {
+		delay := m.delay
+		for attempt := 0; attempt < 4; attempt++ {
+			time.Sleep(delay)
+			delay *= 2
+			if err := m.notify(notifyURL, event); err != nil {
+				global.Logger.Warn("模拟支付回调失败", zap.String("paymentNo", event.PaymentNo), zap.Error(err))
+				continue
+			}
+			return
+		}
+	}()
+}
+
+func (m *MockProvider) notify(notifyURL string, event *CallbackEvent) error {
+	body, signature, err := m.SignedRequest(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, notifyURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(MockSignatureHeader, signature)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("回调返回 %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package payment
+
+import (
+	"bookstore-manager/config"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestMockProvider() *MockProvider {
+	return NewMockProvider(config.PaymentConfig{
+		BaseURL: "http://localhost:8080",
+		Mock:    config.MockPaymentConfig{Enabled: true, Secret: "test_secret"},
+	})
+}
+
+func TestMockVerifyCallback(t *testing.T) {
+	m := newTestMockProvider()
+	body := []byte(`{"type":"payment","payment_no":"P1","trade_no":"T1","status":"succeeded","amount":100}`)
+	signed := func(at time.Time, body []byte) string {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(m.sign(ts, body)))
+	}
+	other := NewMockProvider(config.PaymentConfig{Mock: config.MockPaymentConfig{Secret: "other_secret"}})
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	wrongSecret := fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(other.sign(ts, body)))
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+		wantErr   error
+	}{
+		{"valid", signed(now, body), body, nil},
+		{"valid with spaces", " t=" + ts + ", v1=" + hex.EncodeToString(m.sign(ts, body)), body, nil},
+		{"within tolerance in the past", signed(now.Add(-mockSignatureTolerance+time.Minute), body), body, nil},
+		{"within tolerance in the future", signed(now.Add(mockSignatureTolerance-time.Minute), body), body, nil},
+		{"expired timestamp", signed(now.Add(-mockSignatureTolerance-time.Minute), body), body, ErrInvalidSignature},
+		{"timestamp too far in the future", signed(now.Add(mockSignatureTolerance+time.Minute), body), body, ErrInvalidSignature},
+		{"tampered body", signed(now, body), []byte(`{"type":"payment","payment_no":"P1","amount":1}`), ErrInvalidSignature},
+		{"wrong secret", wrongSecret, body, ErrInvalidSignature},
+		{"missing header", "", body, ErrInvalidSignature},
+		{"missing signature", "t=" + ts, body, ErrInvalidSignature},
+		{"non hex signature", "t=" + ts + ",v1=zz", body, ErrInvalidSignature},
+		{"non numeric timestamp", "t=abc,v1=00", body, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.signature != "" {
+				header.Set(MockSignatureHeader, tt.signature)
+			}
+			event, err := m.VerifyCallback(header, tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyCallback() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && event.PaymentNo != "P1" {
+				t.Errorf("PaymentNo = %q, want P1", event.PaymentNo)
+			}
+		})
+	}
+}
+
+func TestMockSignedRequestRoundTrip(t *testing.T) {
+	m := newTestMockProvider()
+	event := &CallbackEvent{Type: "refund", PaymentNo: "P2", RefundNo: "R1", Status: StatusSucceeded, Amount: 30}
+	body, signature, err := m.SignedRequest(event)
+	if err != nil {
+		t.Fatalf("SignedRequest() error: %v", err)
+	}
+	header := http.Header{}
+	header.Set(MockSignatureHeader, signature)
+	got, err := m.VerifyCallback(header, body)
+	if err != nil {
+		t.Fatalf("VerifyCallback() error: %v", err)
+	}
+	if *got != *event {
+		t.Errorf("VerifyCallback() = %+v, want %+v", got, event)
+	}
+}
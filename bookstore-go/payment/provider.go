@@ -0,0 +1,151 @@
+package payment
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// 支付渠道返回的交易状态
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// 回调事件类型
+const (
+	EventPayment = "payment"
+	EventRefund  = "refund"
+)
+
+var (
+	// ErrInvalidSignature 回调签名错误或已过期
+	ErrInvalidSignature = errors.New("回调签名校验失败")
+	// ErrTradeNotFound 支付渠道查不到这笔交易
+	ErrTradeNotFound = errors.New("交易不存在")
+)
+
+// IntentRequest 创建支付单的参数，金额单位与订单一致（元）
+type IntentRequest struct {
+	PaymentNo string
+	OrderNo   string
+	Subject   string
+	Amount    int
+	NotifyURL string
+}
+
+// Intent 支付渠道返回的支付单，用户打开 PayURL 完成付款
+type Intent struct {
+	TradeNo string
+	PayURL  string
+}
+
+// QueryResult 主动查询的交易结果
+type QueryResult struct {
+	PaymentNo string
+	TradeNo   string
+	Status    string
+	Amount    int
+}
+
+// RefundRequest 退款参数，同一笔支付可以多次部分退款，RefundNo 用于去重
+type RefundRequest struct {
+	PaymentNo string
+	RefundNo  string
+	Amount    int
+	Reason    string
+}
+
+// RefundResult 退款受理结果，Status 为 pending 时以退款回调为准
+type RefundResult struct {
+	RefundNo string
+	TradeNo  string
+	Status   string
+}
+
+// CallbackEvent 验签通过后的回调内容
+type CallbackEvent struct {
+	Type      string `json:"type"`
+	PaymentNo string `json:"payment_no"`
+	RefundNo  string `json:"refund_no,omitempty"`
+	TradeNo   string `json:"trade_no"`
+	Status    string `json:"status"`
+	Amount    int    `json:"amount"`
+}
+
+// PaymentProvider 支付渠道接口，业务代码只依赖这个接口，具体渠道由配置决定
+type PaymentProvider interface {
+	// Name 渠道名，同时用于回调地址 /payment/webhook/<name>
+	Name() string
+	CreateIntent(req *IntentRequest) (*Intent, error)
+	Query(paymentNo string) (*QueryResult, error)
+	Refund(req *RefundRequest) (*RefundResult, error)
+	// VerifyCallback 校验回调签名并解析内容，签名不通过返回 ErrInvalidSignature
+	VerifyCallback(header http.Header, body []byte) (*CallbackEvent, error)
+}
+
+// Default 当前使用的支付渠道，在 main.go 中通过 InitPayment 初始化
+var Default PaymentProvider
+
+// InitPayment 根据配置选择支付渠道
+func InitPayment() error {
+	cfg := config.AppConfig.Payment
+	switch cfg.Provider {
+	case "":
+		return fmt.Errorf("未配置支付渠道 payment.provider")
+	case "mock":
+		if !cfg.Mock.Enabled {
+			return fmt.Errorf("mock 支付仅用于开发环境，需要设置 payment.mock.enabled 或环境变量 BOOKSTORE_PAYMENT_MOCK_ENABLED=true")
+		}
+		switch {
+		case cfg.Mock.Secret == "":
+			// 模拟网关的回调由本进程发出，没有配置密钥时每次启动随机生成一个
+			secret, err := randomSecret()
+			if err != nil {
+				return err
+			}
+			cfg.Mock.Secret = secret
+		case strings.HasPrefix(cfg.Mock.Secret, "change_me"):
+			return fmt.Errorf("mock 支付的 secret 仍是示例值，请修改或留空")
+		}
+		Default = NewMockProvider(cfg)
+	default:
+		return fmt.Errorf("不支持的支付渠道: %s", cfg.Provider)
+	}
+	global.Logger.Info("支付服务初始化成功", zap.String("provider", Default.Name()))
+	return nil
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MockActive 当前是否使用本地模拟网关，只有此时才注册模拟收银台接口
+func MockActive() bool {
+	return Default != nil && Default.Name() == "mock"
+}
+
+// Get 按渠道名取支付渠道，回调地址中的渠道名与当前渠道不一致时返回 false
+func Get(name string) (PaymentProvider, bool) {
+	if Default == nil || Default.Name() != name {
+		return nil, false
+	}
+	return Default, true
+}
+
+// NotifyURL 渠道回调地址
+func NotifyURL(provider string) string {
+	return strings.TrimRight(config.AppConfig.Payment.BaseURL, "/") + "/api/v1/payment/webhook/" + provider
+}
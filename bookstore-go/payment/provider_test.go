@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestInitPaymentMock(t *testing.T) {
+	global.Logger = zap.NewNop()
+	t.Cleanup(func() {
+		global.Logger = nil
+		Default = nil
+	})
+
+	tests := []struct {
+		name    string
+		mock    config.MockPaymentConfig
+		wantErr bool
+	}{
+		{"disabled by default", config.MockPaymentConfig{}, true},
+		{"placeholder secret rejected", config.MockPaymentConfig{Enabled: true, Secret: "change_me_mock_payment_secret"}, true},
+		{"empty secret generated", config.MockPaymentConfig{Enabled: true}, false},
+		{"configured secret", config.MockPaymentConfig{Enabled: true, Secret: "a_real_secret"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Default = nil
+			config.AppConfig.Payment = config.PaymentConfig{Provider: "mock", Mock: tt.mock}
+			err := InitPayment()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitPayment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if MockActive() {
+					t.Error("mock routes would be registered although initialization failed")
+				}
+				return
+			}
+			mock := Default.(*MockProvider)
+			if len(mock.secret) == 0 {
+				t.Error("mock provider has an empty signing secret")
+			}
+		})
+	}
+}
+
+func TestInitPaymentGeneratesDistinctSecrets(t *testing.T) {
+	global.Logger = zap.NewNop()
+	t.Cleanup(func() {
+		global.Logger = nil
+		Default = nil
+	})
+	config.AppConfig.Payment = config.PaymentConfig{Provider: "mock", Mock: config.MockPaymentConfig{Enabled: true}}
+
+	if err := InitPayment(); err != nil {
+		t.Fatalf("InitPayment: %v", err)
+	}
+	first := string(Default.(*MockProvider).secret)
+	if err := InitPayment(); err != nil {
+		t.Fatalf("InitPayment: %v", err)
+	}
+	if second := string(Default.(*MockProvider).secret); second == first {
+		t.Error("generated secret is not random")
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"strconv"
 
 	"bookstore-manager/global"
@@ -11,6 +12,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrStockConflict 减少库存时当前库存已不足（期间有订单扣减），需要刷新后重新编辑
+var ErrStockConflict = errors.New("库存已被订单占用，请刷新后重新设置")
+
 type BookDAO struct {
 	// db 持有 GORM 数据库连接实例，所有用户相关的数据库操作都通过它执行
 	db *gorm.DB
@@ -160,3 +164,127 @@ func (b *BookDAO) GetBooksByCategory(categoryName string, page, pageSize int) ([
 	}
 	return books, total, nil
 }
+
+// BookFilter 后台图书列表的筛选条件，零值表示不过滤
+type BookFilter struct {
+	Title      string
+	Author     string
+	Type       string
+	CategoryID int64
+	Status     *int
+}
+
+// ListBooksForAdmin 后台图书列表（不限上下架状态，支持按标题/作者/类型/分类/状态筛选）
+func (b *BookDAO) ListBooksForAdmin(filter *BookFilter, page, pageSize int) ([]*model.Book, int64, error) {
+	var books []*model.Book
+	var total int64
+
+	query := b.db.Debug().Model(&model.Book{})
+	if filter.Title != "" {
+		query = query.Where("title LIKE ?", "%"+filter.Title+"%")
+	}
+	if filter.Author != "" {
+		query = query.Where("author LIKE ?", "%"+filter.Author+"%")
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.CategoryID != 0 {
+		query = query.Where("category_id = ?", filter.CategoryID)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&books).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
+
+// GetBookByIDAnyStatus 根据ID获取图书（包含已下架的图书，后台使用）
+func (b *BookDAO) GetBookByIDAnyStatus(id int64) (*model.Book, error) {
+	var book model.Book
+	if err := b.db.Debug().First(&book, id).Error; err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// GetBooksByIDs 批量获取图书（包含已下架的），购物车等需要展示失效商品的场景使用
+func (b *BookDAO) GetBooksByIDs(ids []int64) ([]*model.Book, error) {
+	var books []*model.Book
+	if len(ids) == 0 {
+		return books, nil
+	}
+	err := b.db.Where("id IN ?", ids).Find(&books).Error
+	return books, err
+}
+
+// CheckISBNExists 检查ISBN是否已被其他图书使用，excludeID 用于更新时排除自身
+func (b *BookDAO) CheckISBNExists(isbn string, excludeID int64) (bool, error) {
+	var count int64
+	err := b.db.Model(&model.Book{}).Where("isbn = ? AND id <> ?", isbn, excludeID).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (b *BookDAO) CreateBook(book *model.Book) error {
+	return b.db.Debug().Create(book).Error
+}
+
+// UpdateBook 只更新后台可编辑的字段，销量等由下单维护的字段不写回。
+// 库存按差值调整而不是直接覆盖，避免覆盖掉读取之后被订单扣减的库存；减少时库存不能变成负数
+func (b *BookDAO) UpdateBook(book *model.Book, stockDelta int) error {
+	query := b.db.Debug().Model(&model.Book{}).Where("id = ?", book.ID)
+	if stockDelta < 0 {
+		query = query.Where("stock >= ?", -stockDelta)
+	}
+	result := query.Updates(map[string]interface{}{
+		"title":       book.Title,
+		"author":      book.Author,
+		"price":       book.Price,
+		"discount":    book.Discount,
+		"type":        book.Type,
+		"stock":       gorm.Expr("stock + ?", stockDelta),
+		"description": book.Description,
+		"cover_url":   book.CoverURL,
+		"isbn":        book.ISBN,
+		"publisher":   book.Publisher,
+		"pages":       book.Pages,
+		"language":    book.Language,
+		"format":      book.Format,
+		"category_id": book.CategoryID,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if stockDelta < 0 && result.RowsAffected == 0 {
+		return ErrStockConflict
+	}
+	return nil
+}
+
+// DeleteBook 删除图书（软删除）
+func (b *BookDAO) DeleteBook(id int64) error {
+	return b.db.Debug().Delete(&model.Book{}, id).Error
+}
+
+// UpdateBookStatus 上架/下架图书
+func (b *BookDAO) UpdateBookStatus(id int64, status int) error {
+	return b.db.Debug().Model(&model.Book{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// CountBooks 统计图书总数（不区分上下架）
+func (b *BookDAO) CountBooks() (int64, error) {
+	var count int64
+	err := b.db.Model(&model.Book{}).Count(&count).Error
+	return count, err
+}
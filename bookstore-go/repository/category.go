@@ -15,16 +15,134 @@ func NewCategoryDAO() *CategoryDAO {
 	return &CategoryDAO{DB: global.GetDB()}
 }
 
-// GetAll 获取所有分类
+// GetAll 获取所有分类（后台使用，包含未启用的分类）
+// book_count 为物化字段，由 RefreshBookCount 在图书变动时维护，这里直接读取
 func (c *CategoryDAO) GetAll() ([]*model.Category, error) {
 	var categories []*model.Category
-	
-	// 使用子查询：在查询 Category 的同时，去 Books 表查一下有多少本书属于这个分类
-	// books.status = 1 确保只统计已上架的书
-	err := c.DB.Table("categories").
-		Select("categories.*, (SELECT count(*) FROM books WHERE books.category_id = categories.id AND books.status = 1) as book_count").
-		Order("sort ASC").
-		Find(&categories).Error
-		
+	err := c.DB.Order("sort ASC, created_at ASC").Find(&categories).Error
 	return categories, err
-}
\ No newline at end of file
+}
+
+// GetActive 获取已启用的分类（店面使用）
+func (c *CategoryDAO) GetActive() ([]*model.Category, error) {
+	var categories []*model.Category
+	err := c.DB.Where("is_active = ?", true).Order("sort ASC, created_at ASC").Find(&categories).Error
+	return categories, err
+}
+
+// GetByID 根据ID获取分类
+func (c *CategoryDAO) GetByID(id int64) (*model.Category, error) {
+	var category model.Category
+	if err := c.DB.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// CheckNameExists 检查分类名是否已被其他分类使用，excludeID 用于更新时排除自身
+func (c *CategoryDAO) CheckNameExists(name string, excludeID int64) (bool, error) {
+	var count int64
+	err := c.DB.Model(&model.Category{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Create 新增分类。is_active 带有 default:true，需要 Select("*") 才能写入 false
+func (c *CategoryDAO) Create(category *model.Category) error {
+	return c.DB.Debug().Select("*").Create(category).Error
+}
+
+// Update 更新分类，改名时同步更新图书上冗余的分类名(type)
+// 只写可编辑的字段，book_count 由 RefreshBookCount 维护，不能用读出来的旧值覆盖
+func (c *CategoryDAO) Update(category *model.Category, oldName string) error {
+	return c.DB.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Category{}).Where("id = ?", category.ID).Updates(map[string]interface{}{
+			"name":        category.Name,
+			"description": category.Description,
+			"icon":        category.Icon,
+			"color":       category.Color,
+			"gradient":    category.Gradient,
+			"sort":        category.Sort,
+			"is_active":   category.IsActive,
+		}).Error; err != nil {
+			return err
+		}
+		if oldName != category.Name {
+			if err := tx.Model(&model.Book{}).Where("category_id = ?", category.ID).
+				Update("type", category.Name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CountBooks 统计引用该分类的图书数量（不区分上下架）
+func (c *CategoryDAO) CountBooks(categoryID int64) (int64, error) {
+	var count int64
+	err := c.DB.Model(&model.Book{}).Where("category_id = ?", categoryID).Count(&count).Error
+	return count, err
+}
+
+// Delete 删除分类。reassignTo 不为 nil 时先把该分类下的图书迁移到目标分类
+// 分类名有唯一索引，这里做物理删除，避免软删除的记录占用名称
+func (c *CategoryDAO) Delete(id int64, reassignTo *model.Category) error {
+	return c.DB.Debug().Transaction(func(tx *gorm.DB) error {
+		if reassignTo != nil {
+			if err := tx.Model(&model.Book{}).Where("category_id = ?", id).Updates(map[string]interface{}{
+				"category_id": reassignTo.ID,
+				"type":        reassignTo.Name,
+			}).Error; err != nil {
+				return err
+			}
+			if err := refreshBookCount(tx, reassignTo.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&model.Category{}, id).Error
+	})
+}
+
+// UpdateSorts 批量调整分类排序，key 为分类ID，value 为排序权重
+func (c *CategoryDAO) UpdateSorts(sorts map[int64]int) error {
+	return c.DB.Debug().Transaction(func(tx *gorm.DB) error {
+		for id, sort := range sorts {
+			if err := tx.Model(&model.Category{}).Where("id = ?", id).Update("sort", sort).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RefreshBookCount 重新计算指定分类的 book_count（只统计已上架的图书）
+func (c *CategoryDAO) RefreshBookCount(categoryIDs ...int64) error {
+	for _, id := range categoryIDs {
+		if id == 0 {
+			continue
+		}
+		if err := refreshBookCount(c.DB, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshAllBookCounts 重新计算所有分类的 book_count，启动时用于校正历史数据
+func (c *CategoryDAO) RefreshAllBookCounts() error {
+	return c.DB.Exec(`UPDATE categories SET book_count = (
+		SELECT COUNT(*) FROM books
+		WHERE books.category_id = categories.id AND books.status = 1 AND books.deleted_at IS NULL
+	) WHERE categories.deleted_at IS NULL`).Error
+}
+
+func refreshBookCount(db *gorm.DB, categoryID int64) error {
+	var count int64
+	if err := db.Model(&model.Book{}).Where("category_id = ? AND status = ?", categoryID, 1).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	return db.Model(&model.Category{}).Where("id = ?", categoryID).Update("book_count", count).Error
+}
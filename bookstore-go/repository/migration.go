@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MigrationDAO struct {
+	db *gorm.DB
+}
+
+func NewMigrationDAO() *MigrationDAO {
+	return &MigrationDAO{db: global.GetDB()}
+}
+
+// RunOnce 在事务中执行数据迁移并记录，已记录过的迁移直接跳过。
+// 多个实例同时启动时只有抢到记录的那个会真正执行
+func (m *MigrationDAO) RunOnce(name string, migrate func(tx *gorm.DB) error) (bool, error) {
+	applied := false
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.DataMigration{Name: name, AppliedAt: time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		applied = true
+		return migrate(tx)
+	})
+	return applied, err
+}
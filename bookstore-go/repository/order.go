@@ -4,10 +4,19 @@ import (
 	"bookstore-manager/global"
 	"bookstore-manager/model"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrStockInsufficient 预占库存时图书已下架或库存不足
+	ErrStockInsufficient = errors.New("库存不足")
+	// ErrOrderStateChanged 订单状态已被其他请求修改（如同时支付和取消）
+	ErrOrderStateChanged = errors.New("订单状态已变更，请刷新后重试")
+)
+
 type OrderDAO struct {
 	db *gorm.DB
 }
@@ -18,9 +27,16 @@ func NewOrderDAO() *OrderDAO {
 	}
 }
 
+// CreateOrderWithItems 创建订单并在同一事务中预占库存，任意一本书库存不足则整体回滚
 func (o *OrderDAO) CreateOrderWithItems(order *model.Order, items []*model.OrderItem) error {
+	order.StockReserved = true
 	//事务
 	err := o.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if err := reserveStock(tx, item.BookID, item.Quantity); err != nil {
+				return err
+			}
+		}
 		if err := tx.Create(order).Debug().Error; err != nil {
 			return err
 		}
@@ -36,6 +52,20 @@ func (o *OrderDAO) CreateOrderWithItems(order *model.Order, items []*model.Order
 	return err
 }
 
+// reserveStock 条件更新扣减库存，由数据库保证并发下不会超卖
+func reserveStock(tx *gorm.DB, bookID int64, quantity int) error {
+	result := tx.Model(&model.Book{}).
+		Where("id = ? AND status = ? AND stock >= ?", bookID, 1, quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStockInsufficient
+	}
+	return nil
+}
+
 func (o *OrderDAO) GetUserOrders(userID int64, page, pageSize int) ([]*model.Order, int64, error) {
 	var orders []*model.Order
 	var total int64
@@ -55,46 +85,89 @@ func (o *OrderDAO) GetUserOrders(userID int64, page, pageSize int) ([]*model.Ord
 	return orders, total, nil
 }
 
-func (o *OrderDAO) UpdateOrderStatus(order *model.Order) error {
-	//是否需要更新多张表
+// OrderTransition 一次订单状态变更，Fields 为需要同时更新的其他字段
+type OrderTransition struct {
+	To        int
+	ActorType string
+	ActorID   int64
+	Reason    string
+	Fields    map[string]interface{}
+}
+
+// OrderTransitionError 状态表中不允许的变更
+type OrderTransitionError struct {
+	From int
+	To   int
+}
 
-	//订单号、销量、库存、金额、订单状态都要更新
-	//所以考虑用事务来进行原子操作
-	err := o.db.Debug().Transaction(func(tx *gorm.DB) error {
-		for _, item := range order.OrderItems {
-			var book model.Book
-			if err := tx.First(&book, item.BookID).Error; err != nil {
-				return errors.New("图书不存在")
-			}
-			if book.Stock < item.Quantity {
-				return errors.New("库存不足")
-			}
+func (e *OrderTransitionError) Error() string {
+	return fmt.Sprintf("订单不能从 %s 变更为 %s", model.OrderStatusName(e.From), model.OrderStatusName(e.To))
+}
+
+// TransitionOrder 按状态表变更订单状态并写入变更记录，effect 在同一事务中执行附带的库存、销量等修改。
+// 状态以 status = 当前状态 为条件更新，并发修改时只有一个请求会生效，其余返回 ErrOrderStateChanged
+func (o *OrderDAO) TransitionOrder(order *model.Order, t *OrderTransition, effect func(tx *gorm.DB) error) error {
+	if !model.CanTransitOrder(order.Status, t.To) {
+		return &OrderTransitionError{From: order.Status, To: t.To}
+	}
+	fields := map[string]interface{}{"status": t.To}
+	for k, v := range t.Fields {
+		fields[k] = v
+	}
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Order{}).Where("id = ? AND status = ?", order.ID, order.Status).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderStateChanged
+		}
+		history := &model.OrderStatusHistory{
+			OrderID:    order.ID,
+			FromStatus: order.Status,
+			ToStatus:   t.To,
+			ActorType:  t.ActorType,
+			ActorID:    t.ActorID,
+			Reason:     t.Reason,
 		}
-		if err := tx.Model(&model.Order{}).Where("id = ?", order.ID).Updates(
-			map[string]interface{}{
-				"status":       1,
-				"is_paid":      true,
-				"payment_time": gorm.Expr("NOW()"),
-			}).Error; err != nil {
+		if err := tx.Create(history).Error; err != nil {
 			return err
 		}
-		//进行销量和库存的更新
-		for _, item := range order.OrderItems {
-			if err := tx.Model(&model.Book{}).Where("id = ?", item.BookID).Updates(map[string]interface{}{
-				"stock": gorm.Expr("stock - ?", item.Quantity),
-				"sale":  gorm.Expr("sale + ?", item.Quantity),
-			}).Error; err != nil {
-				return err
-			}
+		if effect != nil {
+			return effect(tx)
 		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
+	order.Status = t.To
 	return nil
 }
 
+// PayOrder 支付订单：库存在下单时已预占，这里只确认订单并累加销量（历史订单此时才扣库存）
+func (o *OrderDAO) PayOrder(order *model.Order, t *OrderTransition) error {
+	t.To = model.OrderStatusPaid
+	t.Fields = map[string]interface{}{
+		"is_paid":      true,
+		"payment_time": gorm.Expr("NOW()"),
+	}
+	return o.TransitionOrder(order, t, func(tx *gorm.DB) error {
+		for _, item := range order.OrderItems {
+			if !order.StockReserved {
+				if err := reserveStock(tx, item.BookID, item.Quantity); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&model.Book{}).Where("id = ?", item.BookID).
+				Update("sale", gorm.Expr("sale + ?", item.Quantity)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (o *OrderDAO) GetOrderByID(id int64) (*model.Order, error) {
 	var order model.Order
 	err := o.db.Debug().Preload("OrderItems.Book").First(&order, id).Error
@@ -104,7 +177,161 @@ func (o *OrderDAO) GetOrderByID(id int64) (*model.Order, error) {
 	return &order, nil
 }
 
-// CancelOrder 取消订单（状态设置为2）
-func (o *OrderDAO) CancelOrder(orderID int64) error {
-	return o.db.Model(&model.Order{}).Where("id = ?", orderID).Update("status", 2).Error
+// GetOrderByNo 按订单号查询订单
+func (o *OrderDAO) GetOrderByNo(orderNo string) (*model.Order, error) {
+	var order model.Order
+	err := o.db.Preload("OrderItems.Book").Where("order_no = ?", orderNo).First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrderDetail 订单详情，额外加载状态变更记录和退款单
+func (o *OrderDAO) GetOrderDetail(id int64) (*model.Order, error) {
+	var order model.Order
+	err := o.db.Preload("OrderItems.Book").
+		Preload("StatusHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).
+		Preload("Refunds", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC")
+		}).
+		Preload("Refunds.Items").
+		First(&order, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CancelOrder 取消待支付的订单并归还预占的库存
+func (o *OrderDAO) CancelOrder(order *model.Order, t *OrderTransition) error {
+	t.To = model.OrderStatusCancelled
+	return o.TransitionOrder(order, t, func(tx *gorm.DB) error {
+		if !order.StockReserved {
+			return nil
+		}
+		for _, item := range order.OrderItems {
+			if err := tx.Model(&model.Book{}).Where("id = ?", item.BookID).
+				Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DailyOrderStat 按天汇总的已支付订单数和营收
+type DailyOrderStat struct {
+	Date    string `json:"date"`
+	Orders  int64  `json:"orders"`
+	Revenue int64  `json:"revenue"`
+}
+
+// TopSellingBook 时间范围内的畅销书统计
+type TopSellingBook struct {
+	BookID   int64  `json:"book_id,string"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	CoverURL string `json:"cover_url"`
+	Sales    int64  `json:"sales"`
+	Revenue  int64  `json:"revenue"`
+}
+
+// applyRange 给查询加上 [from, to) 的时间范围，nil 表示不限制
+func applyRange(query *gorm.DB, column string, from, to *time.Time) *gorm.DB {
+	if from != nil {
+		query = query.Where(column+" >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where(column+" < ?", *to)
+	}
+	return query
+}
+
+// CountOrders 统计时间范围内创建的订单数
+func (o *OrderDAO) CountOrders(from, to *time.Time) (int64, error) {
+	var count int64
+	query := applyRange(o.db.Model(&model.Order{}), "created_at", from, to)
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// netRevenueExpr 订单实收金额：订单金额减去支付单上已退款的金额
+const netRevenueExpr = "orders.total_amount - COALESCE((SELECT SUM(payments.refunded_amount) FROM payments " +
+	"WHERE payments.order_id = orders.id AND payments.deleted_at IS NULL), 0)"
+
+// SumPaidRevenue 统计时间范围内已支付订单的营收（按支付时间），已退款的金额不计入
+func (o *OrderDAO) SumPaidRevenue(from, to *time.Time) (int64, error) {
+	var revenue int64
+	query := applyRange(o.db.Model(&model.Order{}).Where("is_paid = ?", true), "payment_time", from, to)
+	err := query.Select("COALESCE(SUM(" + netRevenueExpr + "), 0)").Scan(&revenue).Error
+	return revenue, err
+}
+
+// DailyPaidStats 按支付日期分组统计已支付订单数和营收（扣除退款），没有订单的日期不会返回
+func (o *OrderDAO) DailyPaidStats(from, to time.Time) ([]*DailyOrderStat, error) {
+	var stats []*DailyOrderStat
+	err := o.db.Model(&model.Order{}).
+		Select("DATE_FORMAT(payment_time, '%Y-%m-%d') AS date, COUNT(*) AS orders, COALESCE(SUM("+netRevenueExpr+"), 0) AS revenue").
+		Where("is_paid = ? AND payment_time >= ? AND payment_time < ?", true, from, to).
+		Group("date").
+		Order("date ASC").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// TopSellingBooks 统计时间范围内已支付订单中销量最高的图书，销量和销售额都扣除已退款的数量
+func (o *OrderDAO) TopSellingBooks(from, to *time.Time, limit int) ([]*TopSellingBook, error) {
+	var books []*TopSellingBook
+	query := o.db.Table("order_items").
+		Select("order_items.book_id, books.title, books.author, books.cover_url, "+
+			"SUM(order_items.quantity - order_items.refunded_quantity) AS sales, "+
+			"SUM(order_items.price * (order_items.quantity - order_items.refunded_quantity)) AS revenue").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN books ON books.id = order_items.book_id").
+		Where("orders.is_paid = ? AND orders.deleted_at IS NULL AND order_items.deleted_at IS NULL", true)
+	query = applyRange(query, "orders.payment_time", from, to)
+	err := query.Group("order_items.book_id, books.title, books.author, books.cover_url").
+		Having("sales > 0").
+		Order("sales DESC").
+		Limit(limit).
+		Scan(&books).Error
+	return books, err
+}
+
+// OrderFilter 后台订单列表的筛选条件，零值表示不过滤
+type OrderFilter struct {
+	OrderNo string
+	UserID  int64
+	Status  *int
+}
+
+// ListOrders 后台分页查询订单
+func (o *OrderDAO) ListOrders(filter *OrderFilter, page, pageSize int) ([]*model.Order, int64, error) {
+	var orders []*model.Order
+	var total int64
+
+	query := o.db.Debug().Model(&model.Order{})
+	if filter.OrderNo != "" {
+		query = query.Where("order_no = ?", filter.OrderNo)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := query.Preload("User").Preload("OrderItems.Book").
+		Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&orders).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
 }
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+
+	"gorm.io/gorm"
+)
+
+type PaymentDAO struct {
+	db *gorm.DB
+}
+
+func NewPaymentDAO() *PaymentDAO {
+	return &PaymentDAO{db: global.GetDB()}
+}
+
+func (p *PaymentDAO) CreatePayment(payment *model.Payment) error {
+	return p.db.Create(payment).Error
+}
+
+func (p *PaymentDAO) GetPaymentByNo(paymentNo string) (*model.Payment, error) {
+	var payment model.Payment
+	err := p.db.Where("payment_no = ?", paymentNo).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetPendingPayment 订单最近一笔待付款的支付单
+func (p *PaymentDAO) GetPendingPayment(orderID int64) (*model.Payment, error) {
+	var payment model.Payment
+	err := p.db.Where("order_id = ? AND status = ?", orderID, model.PaymentStatusPending).
+		Order("created_at DESC").First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// UpdatePaymentStatus 以当前状态为条件更新支付单，回调重复到达或与主动查询并发时只有一次会生效
+func (p *PaymentDAO) UpdatePaymentStatus(id int64, from, to string, fields map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{"status": to}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	result := p.db.Model(&model.Payment{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountPayments 订单下某个状态的支付单数量
+func (p *PaymentDAO) CountPayments(orderID int64, status string) (int64, error) {
+	var count int64
+	err := p.db.Model(&model.Payment{}).Where("order_id = ? AND status = ?", orderID, status).Count(&count).Error
+	return count, err
+}
+
+// GetPaidPayment 订单支付成功的支付单（部分退款后仍为 succeeded）
+func (p *PaymentDAO) GetPaidPayment(orderID int64) (*model.Payment, error) {
+	var payment model.Payment
+	err := p.db.Where("order_id = ? AND status = ?", orderID, model.PaymentStatusSucceeded).
+		Order("paid_at ASC").First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
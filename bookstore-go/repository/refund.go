@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrRefundStateChanged 退款单状态已被其他请求修改（如重复审核、重复回调）
+var ErrRefundStateChanged = errors.New("退款单状态已变更，请刷新后重试")
+
+type RefundDAO struct {
+	db *gorm.DB
+}
+
+func NewRefundDAO() *RefundDAO {
+	return &RefundDAO{db: global.GetDB()}
+}
+
+// WithTx 返回使用指定事务的 DAO，用于和订单状态变更放在同一个事务中
+func (r *RefundDAO) WithTx(tx *gorm.DB) *RefundDAO {
+	return &RefundDAO{db: tx}
+}
+
+// CreateRefund 创建退款单及其明细
+func (r *RefundDAO) CreateRefund(refund *model.Refund) error {
+	return r.db.Create(refund).Error
+}
+
+func (r *RefundDAO) GetRefundByID(id int64) (*model.Refund, error) {
+	var refund model.Refund
+	err := r.db.Preload("Items").First(&refund, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *RefundDAO) GetRefundByNo(refundNo string) (*model.Refund, error) {
+	var refund model.Refund
+	err := r.db.Preload("Items").Where("refund_no = ?", refundNo).First(&refund).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// ListOrderRefunds 订单的所有退款单，按申请时间倒序
+func (r *RefundDAO) ListOrderRefunds(orderID int64) ([]*model.Refund, error) {
+	var refunds []*model.Refund
+	err := r.db.Preload("Items").Where("order_id = ?", orderID).Order("created_at DESC").Find(&refunds).Error
+	return refunds, err
+}
+
+// ListRefunds 后台分页查询退款单，status 为空表示不过滤
+func (r *RefundDAO) ListRefunds(status string, page, pageSize int) ([]*model.Refund, int64, error) {
+	var refunds []*model.Refund
+	var total int64
+
+	query := r.db.Model(&model.Refund{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := query.Preload("Items").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&refunds).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return refunds, total, nil
+}
+
+// UpdateRefundStatus 以当前状态为条件更新退款单，状态已变化时返回 ErrRefundStateChanged
+func (r *RefundDAO) UpdateRefundStatus(id int64, from []string, to string, fields map[string]interface{}) error {
+	updates := map[string]interface{}{"status": to}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	result := r.db.Model(&model.Refund{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefundStateChanged
+	}
+	return nil
+}
+
+// ApplyRefund 退款到账后的数据变更：退款单置为成功，累加订单项的已退数量，
+// 归还库存、扣减销量，并累加支付单的已退金额。需要在事务中调用（见 WithTx）
+func (r *RefundDAO) ApplyRefund(refund *model.Refund, paymentID int64) error {
+	err := r.UpdateRefundStatus(refund.ID, []string{model.RefundStatusApproved}, model.RefundStatusRefunded,
+		map[string]interface{}{"refunded_at": gorm.Expr("NOW()")})
+	if err != nil {
+		return err
+	}
+	for _, item := range refund.Items {
+		result := r.db.Model(&model.OrderItem{}).
+			Where("id = ? AND refunded_quantity + ? <= quantity", item.OrderItemID, item.Quantity).
+			Update("refunded_quantity", gorm.Expr("refunded_quantity + ?", item.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("退款数量超过可退数量")
+		}
+		if err := r.db.Model(&model.Book{}).Where("id = ?", item.BookID).Updates(map[string]interface{}{
+			"stock": gorm.Expr("stock + ?", item.Quantity),
+			"sale":  gorm.Expr("GREATEST(sale - ?, 0)", item.Quantity),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	if paymentID == 0 {
+		return nil
+	}
+	// MySQL 按从左到右的顺序执行赋值，status 的判断用的是累加后的 refunded_amount
+	return r.db.Exec("UPDATE payments SET refunded_amount = refunded_amount + ?, "+
+		"status = IF(refunded_amount >= amount, ?, status) WHERE id = ?",
+		refund.Amount, model.PaymentStatusRefunded, paymentID).Error
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+
+	"gorm.io/gorm"
+)
+
+type RoleDAO struct {
+	db *gorm.DB
+}
+
+func NewRoleDAO() *RoleDAO {
+	return &RoleDAO{
+		db: global.GetDB(),
+	}
+}
+
+// WithTx 返回使用指定事务的 DAO
+func (r *RoleDAO) WithTx(tx *gorm.DB) *RoleDAO {
+	return &RoleDAO{db: tx}
+}
+
+// GetAllPermissions 获取所有权限点
+func (r *RoleDAO) GetAllPermissions() ([]*model.Permission, error) {
+	var permissions []*model.Permission
+	err := r.db.Order("code ASC").Find(&permissions).Error
+	return permissions, err
+}
+
+// GetPermissionsByCodes 根据权限编码批量获取权限
+func (r *RoleDAO) GetPermissionsByCodes(codes []string) ([]model.Permission, error) {
+	var permissions []model.Permission
+	err := r.db.Where("code IN ?", codes).Find(&permissions).Error
+	return permissions, err
+}
+
+// EnsurePermission 权限不存在时创建，已存在时更新名称和描述
+func (r *RoleDAO) EnsurePermission(permission *model.Permission) error {
+	var existing model.Permission
+	err := r.db.Where("code = ?", permission.Code).First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		return r.db.Create(permission).Error
+	}
+	if err != nil {
+		return err
+	}
+	permission.ID = existing.ID
+	return r.db.Model(&existing).Updates(map[string]interface{}{
+		"name":        permission.Name,
+		"description": permission.Description,
+	}).Error
+}
+
+// GetAllRoles 获取所有角色及其权限
+func (r *RoleDAO) GetAllRoles() ([]*model.Role, error) {
+	var roles []*model.Role
+	err := r.db.Preload("Permissions").Order("created_at ASC").Find(&roles).Error
+	return roles, err
+}
+
+func (r *RoleDAO) GetRoleByID(id int64) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.Preload("Permissions").First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *RoleDAO) GetRoleByCode(code string) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.Where("code = ?", code).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *RoleDAO) GetRolesByIDs(ids []int64) ([]model.Role, error) {
+	var roles []model.Role
+	err := r.db.Where("id IN ?", ids).Find(&roles).Error
+	return roles, err
+}
+
+// CreateRole 创建角色并关联权限
+func (r *RoleDAO) CreateRole(role *model.Role) error {
+	return r.db.Debug().Create(role).Error
+}
+
+// UpdateRole 更新角色信息，并用 permissions 整体替换角色的权限
+func (r *RoleDAO) UpdateRole(role *model.Role, permissions []model.Permission) error {
+	return r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Updates(map[string]interface{}{
+			"name":        role.Name,
+			"description": role.Description,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(role).Association("Permissions").Replace(permissions)
+	})
+}
+
+// DeleteRole 删除角色，同时解除和权限、用户的关联
+func (r *RoleDAO) DeleteRole(role *model.Role) error {
+	return r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM user_roles WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(role).Error
+	})
+}
+
+// GetUserIDsByRole 获取拥有某个角色的所有用户ID
+func (r *RoleDAO) GetUserIDsByRole(roleID int64) ([]int64, error) {
+	var userIDs []int64
+	err := r.db.Table("user_roles").Where("role_id = ?", roleID).Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetUserPermissionCodes 获取用户通过角色获得的所有权限编码，以及用户拥有的角色编码
+func (r *RoleDAO) GetUserPermissionCodes(userID int64) (roleCodes []string, permCodes []string, err error) {
+	err = r.db.Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.deleted_at IS NULL", userID).
+		Pluck("roles.code", &roleCodes).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	err = r.db.Table("permissions").
+		Distinct("permissions.code").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ? AND permissions.deleted_at IS NULL", userID).
+		Pluck("permissions.code", &permCodes).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return roleCodes, permCodes, nil
+}
+
+// SetUserRoles 用 roles 整体替换用户的角色
+func (r *RoleDAO) SetUserRoles(userID int64, roles []model.Role) error {
+	user := &model.User{}
+	user.ID = userID
+	return r.db.Debug().Model(user).Association("Roles").Replace(roles)
+}
+
+// GetAdminUserIDsWithoutRoles 获取还没有分配任何角色的管理员，用于从 IsAdmin 迁移到角色体系
+func (r *RoleDAO) GetAdminUserIDsWithoutRoles() ([]int64, error) {
+	var userIDs []int64
+	err := r.db.Model(&model.User{}).
+		Where("is_admin = ? AND id NOT IN (SELECT user_id FROM user_roles)", true).
+		Pluck("id", &userIDs).Error
+	return userIDs, err
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SeckillDAO struct {
+	db *gorm.DB
+}
+
+func NewSeckillDAO() *SeckillDAO {
+	return &SeckillDAO{db: global.GetDB()}
+}
+
+func (s *SeckillDAO) CreateCampaign(campaign *model.SeckillCampaign) error {
+	return s.db.Create(campaign).Error
+}
+
+func (s *SeckillDAO) GetCampaignByID(id int64) (*model.SeckillCampaign, error) {
+	var campaign model.SeckillCampaign
+	err := s.db.Preload("Book").First(&campaign, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// UpdateCampaign 更新活动，使用 map 以便把 status 等字段更新为零值
+func (s *SeckillDAO) UpdateCampaign(id int64, fields map[string]interface{}) error {
+	return s.db.Model(&model.SeckillCampaign{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// ListCampaigns 后台分页查询活动，status 为 nil 表示不过滤
+func (s *SeckillDAO) ListCampaigns(status *int, page, pageSize int) ([]*model.SeckillCampaign, int64, error) {
+	var campaigns []*model.SeckillCampaign
+	var total int64
+
+	query := s.db.Model(&model.SeckillCampaign{})
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := query.Preload("Book").Order("start_time DESC").Offset(offset).Limit(pageSize).Find(&campaigns).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return campaigns, total, nil
+}
+
+// ListOpenCampaigns 已启用且尚未结束的活动（包括未开始的），按开始时间排序
+func (s *SeckillDAO) ListOpenCampaigns(now time.Time) ([]*model.SeckillCampaign, error) {
+	var campaigns []*model.SeckillCampaign
+	err := s.db.Preload("Book").Where("status = ? AND end_time > ?", 1, now).
+		Order("start_time ASC").Find(&campaigns).Error
+	return campaigns, err
+}
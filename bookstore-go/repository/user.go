@@ -4,6 +4,7 @@ package repository
 import (
 	"bookstore-manager/global"
 	"bookstore-manager/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -79,3 +80,116 @@ func (u *UserDAO) UpdateUser(user *model.User) error {
 	}
 	return nil
 }
+
+// UserFilter 后台用户列表的筛选条件，零值表示不过滤
+type UserFilter struct {
+	Keyword  string // 匹配用户名、邮箱、手机号
+	Username string
+	Email    string
+	IsAdmin  *bool
+	Status   *int
+}
+
+// ListUsers 后台分页查询用户
+func (u *UserDAO) ListUsers(filter *UserFilter, page, pageSize int) ([]*model.User, int64, error) {
+	var users []*model.User
+	var total int64
+
+	query := u.db.Debug().Model(&model.User{})
+	if filter.Keyword != "" {
+		like := "%" + filter.Keyword + "%"
+		query = query.Where("username LIKE ? OR email LIKE ? OR phone LIKE ?", like, like, like)
+	}
+	if filter.Username != "" {
+		query = query.Where("username LIKE ?", "%"+filter.Username+"%")
+	}
+	if filter.Email != "" {
+		query = query.Where("email LIKE ?", "%"+filter.Email+"%")
+	}
+	if filter.IsAdmin != nil {
+		query = query.Where("is_admin = ?", *filter.IsAdmin)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := query.Preload("Roles").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// CheckContactTaken 检查邮箱或手机号是否已被其他用户占用，excludeID 用于更新时排除自身
+func (u *UserDAO) CheckContactTaken(email, phone string, excludeID int64) (bool, error) {
+	var count int64
+	query := u.db.Model(&model.User{}).Where("id <> ?", excludeID)
+	if phone != "" {
+		query = query.Where("email = ? OR phone = ?", email, phone)
+	} else {
+		query = query.Where("email = ?", email)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// UpdateUserStatus 启用/禁用用户
+func (u *UserDAO) UpdateUserStatus(id int64, status int) error {
+	return u.db.Debug().Model(&model.User{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// CountUsers 统计用户总数
+func (u *UserDAO) CountUsers() (int64, error) {
+	var count int64
+	err := u.db.Model(&model.User{}).Count(&count).Error
+	return count, err
+}
+
+// UpdatePassword 只更新密码字段
+func (u *UserDAO) UpdatePassword(id int64, password string) error {
+	return u.db.Debug().Model(&model.User{}).Where("id = ?", id).Update("password", password).Error
+}
+
+// GetUserByEmail 按邮箱查询用户
+func (u *UserDAO) GetUserByEmail(email string) (*model.User, error) {
+	var user model.User
+	err := u.db.Where("email = ?", email).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// MarkEmailVerified 邮箱仍是发起验证时的地址才标记为已验证，返回是否更新成功
+func (u *UserDAO) MarkEmailVerified(id int64, email string) (bool, error) {
+	result := u.db.Model(&model.User{}).
+		Where("id = ? AND email = ?", id, email).
+		Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateTwoFactor 开启或关闭两步验证，关闭时传空的密钥和恢复码
+func (u *UserDAO) UpdateTwoFactor(id int64, enabled bool, secret, recoveryCodes string) error {
+	return u.db.Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"two_factor_enabled": enabled,
+		"two_factor_secret":  secret,
+		"recovery_codes":     recoveryCodes,
+	}).Error
+}
+
+// ReplaceRecoveryCodes 仅当恢复码仍是 old 时才替换，防止同一个恢复码被并发使用两次
+func (u *UserDAO) ReplaceRecoveryCodes(id int64, old, codes string) (bool, error) {
+	result := u.db.Model(&model.User{}).
+		Where("id = ? AND recovery_codes = ?", id, old).
+		Update("recovery_codes", codes)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"bookstore-manager/jwt"
+	"bookstore-manager/mail"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+	emailVerifyTTL   = 24 * time.Hour
+	// mailCooldown 同一用户两次发信的最小间隔
+	mailCooldown = time.Minute
+)
+
+// AccountService 找回密码、验证邮箱等需要通过邮件完成的账号操作
+type AccountService struct {
+	UserDB *repository.UserDAO
+}
+
+func NewAccountService() *AccountService {
+	return &AccountService{
+		UserDB: repository.NewUserDAO(),
+	}
+}
+
+// RequestPasswordReset 给邮箱发送重置密码链接。无论邮箱是否注册都返回成功，
+// 查询和发信放到后台执行，避免通过响应内容或耗时判断邮箱是否存在
+func (s *AccountService) RequestPasswordReset(email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" || !strings.Contains(email, "@") {
+		return errors.New("邮箱格式不正确")
+	}
+	go func() {
+		user, err := s.UserDB.GetUserByEmail(email)
+		if err != nil || user.Status == model.UserStatusDisabled {
+			return
+		}
+		if !acquireMailCooldown(actionPasswordReset, user.ID) {
+			return
+		}
+		token, err := issueActionToken(actionPasswordReset, user.ID, "", passwordResetTTL)
+		if err != nil {
+			global.Logger.Error("生成重置密码token失败", zap.Error(err), zap.Int64("userID", user.ID))
+			return
+		}
+		err = mail.Send(&mail.Message{
+			To:      user.Email,
+			Subject: "重置您的书城账号密码",
+			Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置密码的请求，请在%d分钟内打开以下链接设置新密码：\n%s\n\n如果不是您本人操作，请忽略本邮件，您的密码不会被修改。\n",
+				user.Username, int(passwordResetTTL.Minutes()), siteLink("/reset-password", token)),
+		})
+		if err != nil {
+			global.Logger.Error("发送重置密码邮件失败", zap.Error(err), zap.Int64("userID", user.ID))
+		}
+	}()
+	return nil
+}
+
+// ResetPassword 使用邮件中的token设置新密码，成功后撤销该用户所有登录
+func (s *AccountService) ResetPassword(token, newPassword string) error {
+	if len(newPassword) < 6 {
+		return errors.New("新密码至少6位")
+	}
+	userID, _, err := consumeActionToken(actionPasswordReset, token)
+	if err != nil {
+		return err
+	}
+	user, err := s.UserDB.GetUserByID(userID)
+	if err != nil {
+		return errActionTokenInvalid
+	}
+	if user.Status == model.UserStatusDisabled {
+		return errors.New("账号已被禁用")
+	}
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return errors.New("密码加密失败")
+	}
+	if err := s.UserDB.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return errors.New("重置密码失败")
+	}
+	if err := jwt.RevokeToken(uint(user.ID)); err != nil {
+		global.Logger.Error("重置密码后撤销token失败", zap.Error(err), zap.Int64("userID", user.ID))
+	}
+	return nil
+}
+
+// SendEmailVerification 给用户当前邮箱发送验证链接
+func (s *AccountService) SendEmailVerification(userID int64) error {
+	user, err := s.UserDB.GetUserByID(userID)
+	if err != nil {
+		return errors.New("用户不存在")
+	}
+	if user.EmailVerified {
+		return errors.New("邮箱已验证")
+	}
+	if !acquireMailCooldown(actionEmailVerify, user.ID) {
+		return errors.New("发送过于频繁，请稍后再试")
+	}
+	return s.sendEmailVerification(user)
+}
+
+func (s *AccountService) sendEmailVerification(user *model.User) error {
+	// token 中记录发起验证时的邮箱，验证前邮箱被修改则链接失效
+	token, err := issueActionToken(actionEmailVerify, user.ID, user.Email, emailVerifyTTL)
+	if err != nil {
+		global.Logger.Error("生成邮箱验证token失败", zap.Error(err), zap.Int64("userID", user.ID))
+		return errors.New("发送验证邮件失败")
+	}
+	err = mail.Send(&mail.Message{
+		To:      user.Email,
+		Subject: "验证您的书城账号邮箱",
+		Body: fmt.Sprintf("%s，您好：\n\n请在24小时内打开以下链接完成邮箱验证：\n%s\n\n如果您没有注册书城账号，请忽略本邮件。\n",
+			user.Username, siteLink("/verify-email", token)),
+	})
+	if err != nil {
+		global.Logger.Error("发送验证邮件失败", zap.Error(err), zap.Int64("userID", user.ID))
+		return errors.New("发送验证邮件失败")
+	}
+	return nil
+}
+
+// VerifyEmail 使用邮件中的token完成邮箱验证
+func (s *AccountService) VerifyEmail(token string) error {
+	userID, email, err := consumeActionToken(actionEmailVerify, token)
+	if err != nil {
+		return err
+	}
+	ok, err := s.UserDB.MarkEmailVerified(userID, email)
+	if err != nil {
+		return errors.New("验证邮箱失败")
+	}
+	if !ok {
+		return errors.New("邮箱已变更，请重新发起验证")
+	}
+	return nil
+}
+
+// acquireMailCooldown 限制发信频率，冷却期内返回 false
+func acquireMailCooldown(purpose string, userID int64) bool {
+	key := fmt.Sprintf("mail_cooldown:%s:%d", purpose, userID)
+	ok, err := global.RedisClient.SetNX(context.Background(), key, 1, mailCooldown).Result()
+	if err != nil {
+		global.Logger.Error("设置发信冷却失败", zap.Error(err))
+		return false
+	}
+	return ok
+}
+
+func siteLink(path, token string) string {
+	base := strings.TrimRight(config.AppConfig.Mail.SiteURL, "/")
+	return base + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 一次性token的用途，不同用途的token互不通用
+const (
+	actionPasswordReset = "pwd_reset"
+	actionEmailVerify   = "email_verify"
+)
+
+var errActionTokenInvalid = errors.New("链接无效或已过期")
+
+// issueActionToken 签发一次性token：随机串 + HMAC签名，Redis 中只保存token的哈希。
+// 同一用户同一用途只保留最新的一个，重新申请会让之前的链接失效
+func issueActionToken(purpose string, userID int64, payload string, ttl time.Duration) (string, error) {
+	secret := config.AppConfig.Security.TokenSecret
+	if secret == "" {
+		return "", errors.New("未配置 security.token_secret")
+	}
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(random)
+	token := nonce + "." + signActionToken(secret, purpose, nonce)
+
+	ctx := context.Background()
+	tokenKey := actionTokenKey(purpose, token)
+	userKey := fmt.Sprintf("action_token:%s:user:%d", purpose, userID)
+	if old, err := global.RedisClient.Get(ctx, userKey).Result(); err == nil {
+		global.RedisClient.Del(ctx, old)
+	}
+
+	pipe := global.RedisClient.TxPipeline()
+	pipe.Set(ctx, tokenKey, fmt.Sprintf("%d|%s", userID, payload), ttl)
+	pipe.Set(ctx, userKey, tokenKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeActionToken 校验签名并消费token，每个token只能成功使用一次
+func consumeActionToken(purpose, token string) (int64, string, error) {
+	nonce, signature, ok := strings.Cut(token, ".")
+	secret := config.AppConfig.Security.TokenSecret
+	if !ok || secret == "" ||
+		!hmac.Equal([]byte(signature), []byte(signActionToken(secret, purpose, nonce))) {
+		return 0, "", errActionTokenInvalid
+	}
+
+	value, err := global.RedisClient.GetDel(context.Background(), actionTokenKey(purpose, token)).Result()
+	if err == redis.Nil {
+		return 0, "", errActionTokenInvalid
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	idStr, payload, _ := strings.Cut(value, "|")
+	userID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, "", errActionTokenInvalid
+	}
+	return userID, payload, nil
+}
+
+func signActionToken(secret, purpose, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func actionTokenKey(purpose, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("action_token:%s:%s", purpose, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type AdminBookService struct {
+	BookDB     *repository.BookDAO
+	CategoryDB *repository.CategoryDAO
+}
+
+func NewAdminBookService() *AdminBookService {
+	return &AdminBookService{
+		BookDB:     repository.NewBookDAO(),
+		CategoryDB: repository.NewCategoryDAO(),
+	}
+}
+
+// AdminBookRequest 后台新增/编辑图书的请求参数
+type AdminBookRequest struct {
+	Title       string `json:"title"`
+	Author      string `json:"author"`
+	Price       int    `json:"price"`
+	Discount    int    `json:"discount"`
+	Type        string `json:"type"`
+	Stock       int    `json:"stock"`
+	Status      *int   `json:"status"` // 仅新增时生效，编辑时状态只能通过 /status 接口修改
+	Description string `json:"description"`
+	CoverURL    string `json:"cover_url"`
+	ISBN        string `json:"isbn"`
+	Publisher   string `json:"publisher"`
+	Pages       int    `json:"pages"`
+	Language    string `json:"language"`
+	Format      string `json:"format"`
+	CategoryID  int64  `json:"category_id,string"`
+}
+
+// Validate 校验图书参数（不涉及数据库的部分）
+func (r *AdminBookRequest) Validate() error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" {
+		return errors.New("书名不能为空")
+	}
+	if r.Price <= 0 {
+		return errors.New("价格必须大于0")
+	}
+	if r.Discount < 0 || r.Discount > 100 {
+		return errors.New("折扣必须在0-100之间")
+	}
+	if r.Stock < 0 {
+		return errors.New("库存不能为负数")
+	}
+	if r.Status != nil && *r.Status != 0 && *r.Status != 1 {
+		return errors.New("状态只能为0(下架)或1(上架)")
+	}
+	if r.Pages < 0 {
+		return errors.New("页数不能为负数")
+	}
+	if r.CoverURL != "" && !isValidCoverURL(r.CoverURL) {
+		return errors.New("封面地址必须是合法的http(s)链接")
+	}
+	if r.ISBN != "" {
+		isbn, ok := normalizeISBN(r.ISBN)
+		if !ok {
+			return errors.New("ISBN格式不正确")
+		}
+		r.ISBN = isbn
+	}
+	return nil
+}
+
+// isValidCoverURL 封面只接受带主机名的 http/https 绝对地址
+func isValidCoverURL(raw string) bool {
+	u, err := url.ParseRequestURI(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// normalizeISBN 去掉连字符和空格后校验 ISBN-10 / ISBN-13 的校验位
+func normalizeISBN(raw string) (string, bool) {
+	isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(raw))
+	switch len(isbn) {
+	case 10:
+		sum := 0
+		for i, ch := range isbn {
+			var v int
+			switch {
+			case ch >= '0' && ch <= '9':
+				v = int(ch - '0')
+			case ch == 'X' && i == 9:
+				v = 10
+			default:
+				return "", false
+			}
+			sum += v * (10 - i)
+		}
+		return isbn, sum%11 == 0
+	case 13:
+		sum := 0
+		for i, ch := range isbn {
+			if ch < '0' || ch > '9' {
+				return "", false
+			}
+			v := int(ch - '0')
+			if i%2 == 1 {
+				v *= 3
+			}
+			sum += v
+		}
+		return isbn, sum%10 == 0
+	}
+	return "", false
+}
+
+func (s *AdminBookService) ListBooks(filter *repository.BookFilter, page, pageSize int) ([]*model.Book, int64, error) {
+	return s.BookDB.ListBooksForAdmin(filter, page, pageSize)
+}
+
+func (s *AdminBookService) GetBook(id int64) (*model.Book, error) {
+	book, err := s.BookDB.GetBookByIDAnyStatus(id)
+	if err != nil {
+		return nil, errors.New("图书不存在")
+	}
+	return book, nil
+}
+
+func (s *AdminBookService) CreateBook(req *AdminBookRequest) (*model.Book, error) {
+	if err := s.checkReferences(req, 0); err != nil {
+		return nil, err
+	}
+	book := &model.Book{}
+	applyBookRequest(book, req)
+	if req.Status != nil {
+		book.Status = *req.Status
+	}
+	if err := s.BookDB.CreateBook(book); err != nil {
+		return nil, err
+	}
+	s.refreshCategoryCount(book.CategoryID)
+	s.syncBookCache(book, 0)
+	return book, nil
+}
+
+func (s *AdminBookService) UpdateBook(id int64, req *AdminBookRequest) (*model.Book, error) {
+	book, err := s.BookDB.GetBookByIDAnyStatus(id)
+	if err != nil {
+		return nil, errors.New("图书不存在")
+	}
+	if err := s.checkReferences(req, id); err != nil {
+		return nil, err
+	}
+	oldCategoryID := book.CategoryID
+	// 库存按编辑前后的差值调整，期间被订单扣减的部分不会被覆盖
+	stockDelta := req.Stock - book.Stock
+	applyBookRequest(book, req)
+	if err := s.BookDB.UpdateBook(book, stockDelta); err != nil {
+		return nil, err
+	}
+	updated, err := s.BookDB.GetBookByIDAnyStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	s.refreshCategoryCount(oldCategoryID, updated.CategoryID)
+	s.syncBookCache(updated, stockDelta)
+	return updated, nil
+}
+
+func (s *AdminBookService) DeleteBook(id int64) error {
+	book, err := s.BookDB.GetBookByIDAnyStatus(id)
+	if err != nil {
+		return errors.New("图书不存在")
+	}
+	if err := s.BookDB.DeleteBook(id); err != nil {
+		return err
+	}
+	s.refreshCategoryCount(book.CategoryID)
+	// 删除后按下架处理，清掉所有店面相关的缓存
+	book.Status = 0
+	s.syncBookCache(book, 0)
+	return nil
+}
+
+// UpdateBookStatus 上架/下架图书，并同步 Redis 中的库存、新书榜和详情缓存
+func (s *AdminBookService) UpdateBookStatus(id int64, status int) (*model.Book, error) {
+	if status != 0 && status != 1 {
+		return nil, errors.New("状态只能为0(下架)或1(上架)")
+	}
+	book, err := s.BookDB.GetBookByIDAnyStatus(id)
+	if err != nil {
+		return nil, errors.New("图书不存在")
+	}
+	if err := s.BookDB.UpdateBookStatus(id, status); err != nil {
+		return nil, err
+	}
+	s.refreshCategoryCount(book.CategoryID)
+	book.Status = status
+	s.syncBookCache(book, 0)
+	return book, nil
+}
+
+// checkReferences 校验分类是否存在、ISBN是否重复
+func (s *AdminBookService) checkReferences(req *AdminBookRequest, excludeID int64) error {
+	if req.CategoryID != 0 {
+		category, err := s.CategoryDB.GetByID(req.CategoryID)
+		if err != nil {
+			return errors.New("分类不存在")
+		}
+		if req.Type == "" {
+			req.Type = category.Name
+		}
+	}
+	if req.ISBN != "" {
+		exists, err := s.BookDB.CheckISBNExists(req.ISBN, excludeID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errors.New("ISBN已存在")
+		}
+	}
+	return nil
+}
+
+// applyBookRequest 把请求中的可编辑字段写入图书，上下架状态不在此处修改
+func applyBookRequest(book *model.Book, req *AdminBookRequest) {
+	book.Title = req.Title
+	book.Author = req.Author
+	book.Price = req.Price
+	book.Discount = req.Discount
+	book.Type = req.Type
+	book.Stock = req.Stock
+	book.Description = req.Description
+	book.CoverURL = req.CoverURL
+	book.ISBN = req.ISBN
+	book.Publisher = req.Publisher
+	book.Pages = req.Pages
+	book.Language = req.Language
+	book.Format = req.Format
+	book.CategoryID = req.CategoryID
+}
+
+// refreshCategoryCount 图书变动后重新计算所属分类的 book_count，失败只记录日志
+func (s *AdminBookService) refreshCategoryCount(categoryIDs ...int64) {
+	if err := s.CategoryDB.RefreshBookCount(categoryIDs...); err != nil {
+		global.Logger.Error("更新分类图书数量失败", zap.Error(err))
+	}
+}
+
+// syncStockScript Redis 中已有库存时按差值调整（期间的下单扣减不会被覆盖），
+// 没有时（新建或重新上架）用数据库中的库存初始化
+const syncStockScript = `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return tonumber(ARGV[2])
+`
+
+// syncBookCache 让店面读取的 Redis 数据和数据库保持一致：
+// 上架的图书同步库存（stockDelta 为本次编辑的库存变化量）和排行榜，下架/删除的图书从中移除，详情缓存统一失效
+func (s *AdminBookService) syncBookCache(book *model.Book, stockDelta int) {
+	ctx := context.Background()
+	stockKey := fmt.Sprintf("stock:%d", book.ID)
+	detailKey := fmt.Sprintf("book:detail:%d", book.ID)
+
+	pipe := global.RedisClient.TxPipeline()
+	if book.Status == 1 {
+		pipe.Eval(ctx, syncStockScript, []string{stockKey}, stockDelta, book.Stock)
+		pipe.ZAdd(ctx, "rank:new_books", redis.Z{
+			Score:  float64(book.CreatedAt.Unix()),
+			Member: book.ID,
+		})
+		pipe.ZAdd(ctx, "rank:hot_books", redis.Z{
+			Score:  float64(book.Sale),
+			Member: book.ID,
+		})
+	} else {
+		pipe.Del(ctx, stockKey)
+		pipe.ZRem(ctx, "rank:new_books", book.ID)
+		pipe.ZRem(ctx, "rank:hot_books", book.ID)
+	}
+	pipe.Del(ctx, detailKey)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		global.Logger.Error("同步图书缓存失败", zap.Error(err), zap.Int64("bookID", book.ID))
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type AdminCategoryService struct {
+	CategoryDB *repository.CategoryDAO
+}
+
+func NewAdminCategoryService() *AdminCategoryService {
+	return &AdminCategoryService{
+		CategoryDB: repository.NewCategoryDAO(),
+	}
+}
+
+// AdminCategoryRequest 后台新增/编辑分类的请求参数
+type AdminCategoryRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	Color       string `json:"color"`
+	Gradient    string `json:"gradient"`
+	Sort        int    `json:"sort"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// CategorySortItem 分类排序项
+type CategorySortItem struct {
+	ID   int64 `json:"id,string"`
+	Sort int   `json:"sort"`
+}
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Validate 校验分类参数（不涉及数据库的部分）
+func (r *AdminCategoryRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("分类名称不能为空")
+	}
+	if len([]rune(r.Name)) > 50 {
+		return errors.New("分类名称不能超过50个字符")
+	}
+	if r.Color != "" && !colorPattern.MatchString(r.Color) {
+		return errors.New("颜色必须是#RGB或#RRGGBB格式")
+	}
+	if r.Gradient != "" && !strings.HasPrefix(r.Gradient, "linear-gradient(") && !strings.HasPrefix(r.Gradient, "radial-gradient(") {
+		return errors.New("渐变色必须是linear-gradient或radial-gradient")
+	}
+	return nil
+}
+
+func (s *AdminCategoryService) ListCategories() ([]*model.Category, error) {
+	return s.CategoryDB.GetAll()
+}
+
+func (s *AdminCategoryService) GetCategory(id int64) (*model.Category, error) {
+	category, err := s.CategoryDB.GetByID(id)
+	if err != nil {
+		return nil, errors.New("分类不存在")
+	}
+	return category, nil
+}
+
+func (s *AdminCategoryService) CreateCategory(req *AdminCategoryRequest) (*model.Category, error) {
+	if err := s.checkName(req.Name, 0); err != nil {
+		return nil, err
+	}
+	category := &model.Category{IsActive: true}
+	applyCategoryRequest(category, req)
+	if err := s.CategoryDB.Create(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *AdminCategoryService) UpdateCategory(id int64, req *AdminCategoryRequest) (*model.Category, error) {
+	category, err := s.CategoryDB.GetByID(id)
+	if err != nil {
+		return nil, errors.New("分类不存在")
+	}
+	if err := s.checkName(req.Name, id); err != nil {
+		return nil, err
+	}
+	oldName := category.Name
+	applyCategoryRequest(category, req)
+	if err := s.CategoryDB.Update(category, oldName); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory 删除分类。分类下仍有图书时，必须指定 reassignTo 把图书迁移到其他分类，否则拒绝删除
+func (s *AdminCategoryService) DeleteCategory(id, reassignTo int64) error {
+	if _, err := s.CategoryDB.GetByID(id); err != nil {
+		return errors.New("分类不存在")
+	}
+	count, err := s.CategoryDB.CountBooks(id)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return s.CategoryDB.Delete(id, nil)
+	}
+	if reassignTo == 0 {
+		return fmt.Errorf("该分类下还有%d本图书，请先迁移到其他分类", count)
+	}
+	if reassignTo == id {
+		return errors.New("不能迁移到被删除的分类")
+	}
+	target, err := s.CategoryDB.GetByID(reassignTo)
+	if err != nil {
+		return errors.New("目标分类不存在")
+	}
+	return s.CategoryDB.Delete(id, target)
+}
+
+// ReorderCategories 批量调整分类排序
+func (s *AdminCategoryService) ReorderCategories(items []CategorySortItem) error {
+	if len(items) == 0 {
+		return errors.New("排序项不能为空")
+	}
+	sorts := make(map[int64]int, len(items))
+	for _, item := range items {
+		sorts[item.ID] = item.Sort
+	}
+	return s.CategoryDB.UpdateSorts(sorts)
+}
+
+func (s *AdminCategoryService) checkName(name string, excludeID int64) error {
+	exists, err := s.CategoryDB.CheckNameExists(name, excludeID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("分类名称已存在")
+	}
+	return nil
+}
+
+func applyCategoryRequest(category *model.Category, req *AdminCategoryRequest) {
+	category.Name = req.Name
+	category.Description = req.Description
+	category.Icon = req.Icon
+	category.Color = req.Color
+	category.Gradient = req.Gradient
+	category.Sort = req.Sort
+	if req.IsActive != nil {
+		category.IsActive = *req.IsActive
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// dashboardCacheTTL 看板数据短暂缓存，避免频繁刷新看板时反复聚合 MySQL
+	dashboardCacheTTL = time.Minute
+	// dashboardDefaultDays 未指定时间范围时，每日趋势默认展示的天数
+	dashboardDefaultDays = 30
+	// dashboardMaxDays 每日趋势允许的最大天数
+	dashboardMaxDays = 366
+	// dashboardRecentBooks 看板"最近添加的图书"展示的数量
+	dashboardRecentBooks = 5
+	dateLayout           = "2006-01-02"
+)
+
+// DashboardRangeError 统计时间范围参数不合法，控制器据此返回 400
+type DashboardRangeError struct {
+	Message string
+}
+
+func (e *DashboardRangeError) Error() string {
+	return e.Message
+}
+
+type DashboardService struct {
+	BookDB  *repository.BookDAO
+	OrderDB *repository.OrderDAO
+	UserDB  *repository.UserDAO
+}
+
+func NewDashboardService() *DashboardService {
+	return &DashboardService{
+		BookDB:  repository.NewBookDAO(),
+		OrderDB: repository.NewOrderDAO(),
+		UserDB:  repository.NewUserDAO(),
+	}
+}
+
+// DashboardStats 后台看板统计数据
+type DashboardStats struct {
+	TotalBooks   int64                        `json:"total_books"`
+	TotalOrders  int64                        `json:"total_orders"`
+	TotalUsers   int64                        `json:"total_users"`
+	TotalRevenue int64                        `json:"total_revenue"`
+	TopBooks     []*repository.TopSellingBook `json:"top_books"`
+	RecentBooks  []*model.Book                `json:"recent_books"` // 最近添加的图书（含未上架），后台首页展示
+	Daily        []*repository.DailyOrderStat `json:"daily"`
+	From         string                       `json:"from"`
+	To           string                       `json:"to"`
+}
+
+// GetStats 获取看板统计数据。from/to 为 "2006-01-02" 格式的闭区间，可以为空：
+// 为空时订单数、营收和畅销榜统计全部历史数据，每日趋势默认展示最近30天；
+// 只传开始日期且距今超过366天时，每日趋势只展示最近366天
+func (s *DashboardService) GetStats(fromStr, toStr string, topN int) (*DashboardStats, error) {
+	var from, to *time.Time
+	if fromStr != "" {
+		t, err := time.ParseInLocation(dateLayout, fromStr, time.Local)
+		if err != nil {
+			return nil, &DashboardRangeError{Message: "开始日期格式应为YYYY-MM-DD"}
+		}
+		from = &t
+	}
+	if toStr != "" {
+		t, err := time.ParseInLocation(dateLayout, toStr, time.Local)
+		if err != nil {
+			return nil, &DashboardRangeError{Message: "结束日期格式应为YYYY-MM-DD"}
+		}
+		// 结束日期包含当天，转换成第二天零点作为开区间上界
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, &DashboardRangeError{Message: "开始日期不能晚于结束日期"}
+	}
+
+	now := time.Now()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	if from != nil && !from.Before(tomorrow) {
+		return nil, &DashboardRangeError{Message: "开始日期不能晚于今天"}
+	}
+
+	// 每日趋势必须有明确的范围
+	dailyTo := tomorrow
+	if to != nil {
+		dailyTo = *to
+	}
+	dailyFrom := dailyTo.AddDate(0, 0, -dashboardDefaultDays)
+	if from != nil {
+		dailyFrom = *from
+	}
+	if dailyTo.Sub(dailyFrom) > dashboardMaxDays*24*time.Hour {
+		if to != nil {
+			return nil, &DashboardRangeError{Message: fmt.Sprintf("统计范围不能超过%d天", dashboardMaxDays)}
+		}
+		// 只传了开始日期时汇总数据照常统计，每日趋势截取最近的 dashboardMaxDays 天
+		dailyFrom = dailyTo.AddDate(0, 0, -dashboardMaxDays)
+	}
+
+	cacheKey := fmt.Sprintf("dashboard:stats:%s:%s:%d", fromStr, toStr, topN)
+	ctx := context.Background()
+	if val, err := global.RedisClient.Get(ctx, cacheKey).Result(); err == nil {
+		var stats DashboardStats
+		if json.Unmarshal([]byte(val), &stats) == nil {
+			return &stats, nil
+		}
+	}
+
+	stats := &DashboardStats{
+		From: dailyFrom.Format(dateLayout),
+		To:   dailyTo.AddDate(0, 0, -1).Format(dateLayout),
+	}
+	var err error
+	if stats.TotalBooks, err = s.BookDB.CountBooks(); err != nil {
+		return nil, err
+	}
+	if stats.TotalUsers, err = s.UserDB.CountUsers(); err != nil {
+		return nil, err
+	}
+	if stats.TotalOrders, err = s.OrderDB.CountOrders(from, to); err != nil {
+		return nil, err
+	}
+	if stats.TotalRevenue, err = s.OrderDB.SumPaidRevenue(from, to); err != nil {
+		return nil, err
+	}
+	if stats.TopBooks, err = s.OrderDB.TopSellingBooks(from, to, topN); err != nil {
+		return nil, err
+	}
+	if stats.RecentBooks, _, err = s.BookDB.ListBooksForAdmin(&repository.BookFilter{}, 1, dashboardRecentBooks); err != nil {
+		return nil, err
+	}
+	daily, err := s.OrderDB.DailyPaidStats(dailyFrom, dailyTo)
+	if err != nil {
+		return nil, err
+	}
+	stats.Daily = fillDailyBuckets(daily, dailyFrom, dailyTo)
+
+	go func() {
+		data, _ := json.Marshal(stats)
+		if err := global.RedisClient.Set(context.Background(), cacheKey, data, dashboardCacheTTL).Err(); err != nil {
+			global.Logger.Error("缓存看板数据失败", zap.Error(err))
+		}
+	}()
+	return stats, nil
+}
+
+// fillDailyBuckets 把数据库返回的稀疏结果补齐成连续的每日数据，没有订单的日期补0
+func fillDailyBuckets(stats []*repository.DailyOrderStat, from, to time.Time) []*repository.DailyOrderStat {
+	byDate := make(map[string]*repository.DailyOrderStat, len(stats))
+	for _, stat := range stats {
+		byDate[stat.Date] = stat
+	}
+	var buckets []*repository.DailyOrderStat
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		if stat, ok := byDate[date]; ok {
+			buckets = append(buckets, stat)
+		} else {
+			buckets = append(buckets, &repository.DailyOrderStat{Date: date})
+		}
+	}
+	return buckets
+}
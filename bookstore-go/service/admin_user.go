@@ -0,0 +1,271 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/jwt"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUserNotFound      = errors.New("用户不存在")
+	ErrUserExists        = errors.New("用户已存在，请检查用户名、邮箱或手机号")
+	ErrContactTaken      = errors.New("邮箱或手机号已被其他用户使用")
+	ErrInvalidUserStatus = errors.New("状态只能为0(禁用)或1(正常)")
+	ErrDisableSelf       = errors.New("不能禁用自己的账号")
+	ErrRevokeSelfAdmin   = errors.New("不能取消自己的管理员权限")
+	// ErrOperatorForbidden 操作者的权限不足以修改目标用户，控制器据此返回 403
+	ErrOperatorForbidden = errors.New("无权修改该用户")
+)
+
+type AdminUserService struct {
+	UserDB *repository.UserDAO
+	RBAC   *RBACService
+}
+
+func NewAdminUserService() *AdminUserService {
+	return &AdminUserService{
+		UserDB: repository.NewUserDAO(),
+		RBAC:   NewRBACService(),
+	}
+}
+
+// AdminCreateUserRequest 后台创建用户的请求参数
+type AdminCreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	IsAdmin  bool   `json:"is_admin"`
+}
+
+// AdminUpdateUserRequest 后台编辑用户的请求参数，Password 为空表示不修改密码
+type AdminUpdateUserRequest struct {
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Avatar   string `json:"avatar"`
+	IsAdmin  *bool  `json:"is_admin"`
+	Password string `json:"password"`
+}
+
+func (r *AdminCreateUserRequest) Validate() error {
+	r.Username = strings.TrimSpace(r.Username)
+	r.Email = strings.TrimSpace(r.Email)
+	if r.Username == "" {
+		return errors.New("用户名不能为空")
+	}
+	if len(r.Password) < 6 {
+		return errors.New("密码至少6位")
+	}
+	if r.Email == "" || !strings.Contains(r.Email, "@") {
+		return errors.New("邮箱格式不正确")
+	}
+	return nil
+}
+
+func (r *AdminUpdateUserRequest) Validate() error {
+	r.Email = strings.TrimSpace(r.Email)
+	if r.Email == "" || !strings.Contains(r.Email, "@") {
+		return errors.New("邮箱格式不正确")
+	}
+	if r.Password != "" && len(r.Password) < 6 {
+		return errors.New("密码至少6位")
+	}
+	return nil
+}
+
+func (s *AdminUserService) ListUsers(filter *repository.UserFilter, page, pageSize int) ([]*model.User, int64, error) {
+	return s.UserDB.ListUsers(filter, page, pageSize)
+}
+
+func (s *AdminUserService) GetUser(id int64) (*model.User, error) {
+	user, err := s.UserDB.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// CreateUser 后台创建用户，创建管理员需要角色管理权限
+func (s *AdminUserService) CreateUser(operatorID int64, req *AdminCreateUserRequest) (*model.User, error) {
+	if err := s.checkOperator(operatorID, nil, req.IsAdmin); err != nil {
+		return nil, err
+	}
+	exists, err := s.UserDB.CheckUserExists(req.Username, req.Phone, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrUserExists
+	}
+	hashedPassword, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, errors.New("密码加密失败")
+	}
+	user := &model.User{
+		Username: req.Username,
+		Password: hashedPassword,
+		Email:    req.Email,
+		Phone:    req.Phone,
+		IsAdmin:  req.IsAdmin,
+		Status:   model.UserStatusActive,
+	}
+	if err := s.UserDB.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser 编辑用户资料，支持设置/取消管理员。
+// 权限或密码发生变化时撤销该用户已签发的 token，让其重新登录
+func (s *AdminUserService) UpdateUser(operatorID, id int64, req *AdminUpdateUserRequest) (*model.User, error) {
+	user, err := s.UserDB.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	changesAdmin := req.IsAdmin != nil && *req.IsAdmin != user.IsAdmin
+	if err := s.checkOperator(operatorID, user, changesAdmin || s.isOtherAdmin(operatorID, user)); err != nil {
+		return nil, err
+	}
+	taken, err := s.UserDB.CheckContactTaken(req.Email, req.Phone, id)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrContactTaken
+	}
+
+	revoke := false
+	if req.IsAdmin != nil && *req.IsAdmin != user.IsAdmin {
+		if id == operatorID && !*req.IsAdmin {
+			return nil, ErrRevokeSelfAdmin
+		}
+		user.IsAdmin = *req.IsAdmin
+		revoke = true
+	}
+	if req.Password != "" {
+		hashedPassword, err := hashPassword(req.Password)
+		if err != nil {
+			return nil, errors.New("密码加密失败")
+		}
+		user.Password = hashedPassword
+		revoke = true
+	}
+	if user.Email != req.Email {
+		user.EmailVerified = false
+		user.EmailVerifiedAt = nil
+	}
+	user.Email = req.Email
+	user.Phone = req.Phone
+	user.Avatar = req.Avatar
+
+	if err := s.UserDB.UpdateUser(user); err != nil {
+		return nil, err
+	}
+	if revoke {
+		s.revokeTokens(user.ID)
+	}
+	return user, nil
+}
+
+// UpdateUserStatus 启用/禁用用户，禁用时立即撤销其所有 token
+func (s *AdminUserService) UpdateUserStatus(operatorID, id int64, status int) (*model.User, error) {
+	if status != model.UserStatusDisabled && status != model.UserStatusActive {
+		return nil, ErrInvalidUserStatus
+	}
+	if id == operatorID && status == model.UserStatusDisabled {
+		return nil, ErrDisableSelf
+	}
+	user, err := s.UserDB.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkOperator(operatorID, user, s.isOtherAdmin(operatorID, user)); err != nil {
+		return nil, err
+	}
+	if err := s.UserDB.UpdateUserStatus(id, status); err != nil {
+		return nil, err
+	}
+	user.Status = status
+	if status == model.UserStatusDisabled {
+		if err := jwt.RevokeToken(uint(id)); err != nil {
+			return nil, errors.New("账号已禁用，但撤销登录状态失败，请重试")
+		}
+	}
+	return user, nil
+}
+
+// SetUserAdmin 设置/取消管理员标记，属于敏感操作，需要角色管理权限，变更后撤销其 token
+func (s *AdminUserService) SetUserAdmin(operatorID, id int64, isAdmin bool) (*model.User, error) {
+	if id == operatorID && !isAdmin {
+		return nil, ErrRevokeSelfAdmin
+	}
+	user, err := s.UserDB.GetUserByID(id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkOperator(operatorID, user, true); err != nil {
+		return nil, err
+	}
+	if user.IsAdmin == isAdmin {
+		return user, nil
+	}
+	user.IsAdmin = isAdmin
+	if err := s.UserDB.UpdateUser(user); err != nil {
+		return nil, err
+	}
+	s.revokeTokens(user.ID)
+	return user, nil
+}
+
+// ResetTwoFactor 用户丢失验证器且没有恢复码时，由管理员关闭其两步验证并强制重新登录
+func (s *AdminUserService) ResetTwoFactor(operatorID, id int64) error {
+	user, err := s.UserDB.GetUserByID(id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := s.checkOperator(operatorID, user, s.isOtherAdmin(operatorID, user)); err != nil {
+		return err
+	}
+	if err := s.UserDB.UpdateTwoFactor(id, false, "", ""); err != nil {
+		return err
+	}
+	s.revokeTokens(id)
+	return nil
+}
+
+// UnlockUser 解除因登录失败次数过多造成的临时锁定
+func (s *AdminUserService) UnlockUser(id int64) error {
+	user, err := s.UserDB.GetUserByID(id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if err := clearLoginFailures(user.Username); err != nil {
+		return errors.New("解锁失败")
+	}
+	return nil
+}
+
+// checkOperator 防止越权修改，规则见 RBACService.CheckOperator。target 为 nil 表示新建用户
+func (s *AdminUserService) checkOperator(operatorID int64, target *model.User, sensitive bool) error {
+	var targetID int64
+	if target != nil {
+		targetID = target.ID
+	}
+	return s.RBAC.CheckOperator(operatorID, targetID, sensitive)
+}
+
+// isOtherAdmin 目标是操作者以外的管理员
+func (s *AdminUserService) isOtherAdmin(operatorID int64, target *model.User) bool {
+	return target.IsAdmin && target.ID != operatorID
+}
+
+func (s *AdminUserService) revokeTokens(userID int64) {
+	if err := jwt.RevokeToken(uint(userID)); err != nil {
+		global.Logger.Error("撤销用户token失败", zap.Error(err), zap.Int64("userID", userID))
+	}
+}
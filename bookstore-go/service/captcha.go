@@ -1,13 +1,10 @@
 package service
 
 import (
-	"bookstore-manager/global"
-	"context"
-	"fmt"
+	"bookstore-manager/config"
 	"time"
 
 	"github.com/mojocn/base64Captcha"
-	"go.uber.org/zap"
 )
 
 type CaptchaService struct {
@@ -15,64 +12,79 @@ type CaptchaService struct {
 }
 
 func NewCaptchaService() *CaptchaService {
+	cfg := config.AppConfig.Captcha
+	expire := cfg.ExpireSeconds
+	if expire <= 0 {
+		expire = 120
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
 	return &CaptchaService{
-		store: base64Captcha.DefaultMemStore,
+		store: NewRedisCaptchaStore(time.Duration(expire)*time.Second, maxAttempts),
 	}
 }
 
 type CaptchaResponse struct {
 	CaptchaID     string `json:"captcha_id"`
 	CaptchaBase64 string `json:"captchaBase64"`
+	Type          string `json:"type"` // 验证码类型，audio 时 captchaBase64 为 wav 音频
 }
 
-// 生成验证码，将验证码ID和图片数据打包成CaptchaResponse结构返回
+// 生成验证码，将验证码ID和图片数据打包成CaptchaResponse结构返回，答案只保存在Redis中
 func (c *CaptchaService) GenerateCaptcha() (*CaptchaResponse, error) {
-	//设置验证码的一些参数
-	driver := base64Captcha.NewDriverDigit(
-		80,  //高度
-		240, //宽度
-		4,   //验证码长度
-		0.7, //干扰强度
-		80,  //干扰数量
-	)
+	captchaType, driver := newCaptchaDriver(config.AppConfig.Captcha)
 	captcha := base64Captcha.NewCaptcha(driver, c.store)
-	id, b64s, answer, err := captcha.Generate()
-	if err != nil {
-		return nil, err
-	}
-	global.Logger.Info("图片验证码真实answer", zap.String("answer", answer))
-
-	//用到redis,作用是存储有效期的图片验证码
-	redisKey := fmt.Sprintf("captcha:%s", id)
-	//key值是redisKey，value是answer
-	err = global.RedisClient.Set(context.TODO(), redisKey, answer, 1*time.Minute).Err()
+	id, b64s, _, err := captcha.Generate()
 	if err != nil {
 		return nil, err
 	}
 	return &CaptchaResponse{
 		CaptchaID:     id,
 		CaptchaBase64: b64s,
+		Type:          captchaType,
 	}, nil
 }
 
 // 校验验证码
 func (c *CaptchaService) VerifyCaptcha(captchaID, captChaValue string) bool {
-	if captchaID == "" || captChaValue == "" {
-		return false
-	}
+	return c.store.Verify(captchaID, captChaValue, true)
+}
 
-	//从Redis获取验证码答案
-	ctx := context.Background()
-	redisKey := fmt.Sprintf("captcha:%s", captchaID)
-	storedAnswer, err := global.RedisClient.Get(ctx, redisKey).Result()
-	if err != nil {
-		return false
+// newCaptchaDriver 根据配置创建验证码驱动，未配置的参数使用默认值
+func newCaptchaDriver(cfg config.CaptchaConfig) (string, base64Captcha.Driver) {
+	length := cfg.Length
+	if length <= 0 {
+		length = 4
+	}
+	width := cfg.Width
+	if width <= 0 {
+		width = 240
+	}
+	height := cfg.Height
+	if height <= 0 {
+		height = 80
+	}
+	noise := cfg.NoiseCount
+	if noise < 0 {
+		noise = 0
 	}
+	lineOptions := base64Captcha.OptionShowHollowLine | base64Captcha.OptionShowSlimeLine
 
-	//比较用户输入的验证码和存储的答案
-	isValid := storedAnswer == captChaValue
-	if isValid {
-		global.RedisClient.Del(ctx, redisKey)
+	switch cfg.Type {
+	case "math":
+		return "math", base64Captcha.NewDriverMath(height, width, noise, lineOptions, nil, nil, nil)
+	case "string":
+		return "string", base64Captcha.NewDriverString(height, width, noise, lineOptions, length,
+			base64Captcha.TxtSimpleCharaters, nil, nil, nil)
+	case "audio":
+		language := cfg.AudioLanguage
+		if language == "" {
+			language = "zh"
+		}
+		return "audio", base64Captcha.NewDriverAudio(length, language)
+	default:
+		return "digit", base64Captcha.NewDriverDigit(height, width, length, 0.7, noise)
 	}
-	return isValid
 }
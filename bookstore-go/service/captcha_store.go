@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// verifyCaptchaScript 原子地校验验证码：答案不区分大小写，每次校验都计数，
+// 答对或次数用完时删除验证码。返回 1 通过，0 不通过，-1 验证码不存在或已过期
+var verifyCaptchaScript = redis.NewScript(`
+local answer = redis.call('GET', KEYS[1])
+if not answer then
+	return -1
+end
+local attempts = redis.call('INCR', KEYS[2])
+if attempts == 1 then
+	redis.call('EXPIRE', KEYS[2], ARGV[2])
+end
+if string.lower(answer) == string.lower(ARGV[1]) then
+	if ARGV[4] == '1' then
+		redis.call('DEL', KEYS[1], KEYS[2])
+	end
+	return 1
+end
+if attempts >= tonumber(ARGV[3]) then
+	redis.call('DEL', KEYS[1], KEYS[2])
+end
+return 0
+`)
+
+// RedisCaptchaStore 基于 Redis 的 base64Captcha.Store 实现，多个服务实例共享验证码
+type RedisCaptchaStore struct {
+	expiration  time.Duration
+	maxAttempts int
+}
+
+func NewRedisCaptchaStore(expiration time.Duration, maxAttempts int) *RedisCaptchaStore {
+	return &RedisCaptchaStore{
+		expiration:  expiration,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func captchaKey(id string) string {
+	return fmt.Sprintf("captcha:%s", id)
+}
+
+func captchaAttemptsKey(id string) string {
+	return fmt.Sprintf("captcha_attempts:%s", id)
+}
+
+func (s *RedisCaptchaStore) Set(id string, value string) error {
+	return global.RedisClient.Set(context.Background(), captchaKey(id), value, s.expiration).Err()
+}
+
+func (s *RedisCaptchaStore) Get(id string, clear bool) string {
+	ctx := context.Background()
+	var value string
+	var err error
+	if clear {
+		value, err = global.RedisClient.GetDel(ctx, captchaKey(id)).Result()
+	} else {
+		value, err = global.RedisClient.Get(ctx, captchaKey(id)).Result()
+	}
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// Verify 校验答案，同一个验证码最多允许 maxAttempts 次尝试
+func (s *RedisCaptchaStore) Verify(id, answer string, clear bool) bool {
+	id = strings.TrimSpace(id)
+	answer = strings.TrimSpace(answer)
+	if id == "" || answer == "" {
+		return false
+	}
+	clearFlag := "0"
+	if clear {
+		clearFlag = "1"
+	}
+	result, err := verifyCaptchaScript.Run(context.Background(), global.RedisClient,
+		[]string{captchaKey(id), captchaAttemptsKey(id)},
+		answer, int64(s.expiration.Seconds()), s.maxAttempts, clearFlag).Int()
+	if err != nil {
+		global.Logger.Error("校验验证码失败", zap.Error(err))
+		return false
+	}
+	return result == 1
+}
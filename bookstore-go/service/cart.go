@@ -0,0 +1,383 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// cartMaxQuantity 单个商品的最大购买数量
+	cartMaxQuantity = 99
+	// cartMaxItems 购物车最多能放多少种商品
+	cartMaxItems = 50
+	userCartTTL  = 30 * 24 * time.Hour
+	anonCartTTL  = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidCartID = errors.New("无效的购物车ID")
+	ErrCartEmpty     = errors.New("购物车为空")
+
+	// 匿名购物车ID由服务端生成，32位十六进制
+	anonCartIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// CartOwner 购物车的归属：登录用户用 UserID，未登录时用服务端下发的匿名购物车ID
+type CartOwner struct {
+	UserID int64
+	CartID string
+}
+
+// CartItem 购物车中的一项，价格等信息实时从图书表读取
+type CartItem struct {
+	BookID    int64  `json:"book_id,string"`
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	CoverURL  string `json:"cover_url"`
+	ListPrice int    `json:"list_price"`
+	Discount  int    `json:"discount"`
+	Price     int    `json:"price"` // 折后单价
+	Stock     int    `json:"stock"`
+	Quantity  int    `json:"quantity"`
+	Subtotal  int    `json:"subtotal"`
+	Available bool   `json:"available"` // 已下架、已删除或库存不足时为 false，结算时会被跳过
+}
+
+type Cart struct {
+	CartID        string      `json:"cart_id,omitempty"` // 匿名购物车ID，客户端需通过 X-Cart-ID 请求头带回
+	Items         []*CartItem `json:"items"`
+	TotalQuantity int         `json:"total_quantity"` // 可结算商品的总件数
+	TotalAmount   int         `json:"total_amount"`   // 可结算商品的总金额
+}
+
+type CartService struct {
+	BookDB       *repository.BookDAO
+	OrderService *OrderService
+}
+
+func NewCartService() *CartService {
+	return &CartService{
+		BookDB:       repository.NewBookDAO(),
+		OrderService: NewOrderService(),
+	}
+}
+
+// NewAnonCartID 生成匿名购物车ID
+func NewAnonCartID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cartKey 登录用户 cart:user:<用户id>，匿名 cart:anon:<购物车id>，hash 的 field 为图书ID，value 为数量
+func cartKey(owner *CartOwner) (string, time.Duration, error) {
+	if owner.UserID > 0 {
+		return fmt.Sprintf("cart:user:%d", owner.UserID), userCartTTL, nil
+	}
+	if !anonCartIDPattern.MatchString(owner.CartID) {
+		return "", 0, ErrInvalidCartID
+	}
+	return fmt.Sprintf("cart:anon:%s", owner.CartID), anonCartTTL, nil
+}
+
+// GetCart 查看购物车
+func (s *CartService) GetCart(owner *CartOwner) (*Cart, error) {
+	key, _, err := cartKey(owner)
+	if err != nil {
+		return nil, err
+	}
+	values, err := global.RedisClient.HGetAll(context.Background(), key).Result()
+	if err != nil {
+		return nil, err
+	}
+	quantities := make(map[int64]int, len(values))
+	for field, value := range values {
+		bookID, err1 := strconv.ParseInt(field, 10, 64)
+		quantity, err2 := strconv.Atoi(value)
+		if err1 != nil || err2 != nil || quantity <= 0 {
+			continue
+		}
+		quantities[bookID] = quantity
+	}
+	cart, err := s.buildCart(quantities)
+	if err != nil {
+		return nil, err
+	}
+	if owner.UserID == 0 {
+		cart.CartID = owner.CartID
+	}
+	return cart, nil
+}
+
+// AddItem 加入购物车，已存在时累加数量
+func (s *CartService) AddItem(owner *CartOwner, bookID int64, quantity int) (*Cart, error) {
+	if quantity <= 0 {
+		return nil, errors.New("数量必须大于0")
+	}
+	key, ttl, err := cartKey(owner)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	field := strconv.FormatInt(bookID, 10)
+	current, err := global.RedisClient.HGet(ctx, key, field).Int()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if current == 0 {
+		count, err := global.RedisClient.HLen(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if count >= cartMaxItems {
+			return nil, fmt.Errorf("购物车最多只能放%d种商品", cartMaxItems)
+		}
+	}
+	if err := s.setQuantity(key, ttl, bookID, current+quantity); err != nil {
+		return nil, err
+	}
+	return s.GetCart(owner)
+}
+
+// UpdateItem 修改购物车中商品的数量，数量为0时移除
+func (s *CartService) UpdateItem(owner *CartOwner, bookID int64, quantity int) (*Cart, error) {
+	if quantity < 0 {
+		return nil, errors.New("数量不能为负数")
+	}
+	if quantity == 0 {
+		return s.RemoveItem(owner, bookID)
+	}
+	key, ttl, err := cartKey(owner)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := global.RedisClient.HExists(context.Background(), key, strconv.FormatInt(bookID, 10)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("购物车中没有该商品")
+	}
+	if err := s.setQuantity(key, ttl, bookID, quantity); err != nil {
+		return nil, err
+	}
+	return s.GetCart(owner)
+}
+
+// RemoveItem 从购物车移除商品
+func (s *CartService) RemoveItem(owner *CartOwner, bookID int64) (*Cart, error) {
+	key, _, err := cartKey(owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := global.RedisClient.HDel(context.Background(), key, strconv.FormatInt(bookID, 10)).Err(); err != nil {
+		return nil, err
+	}
+	return s.GetCart(owner)
+}
+
+// ClearCart 清空购物车
+func (s *CartService) ClearCart(owner *CartOwner) error {
+	key, _, err := cartKey(owner)
+	if err != nil {
+		return err
+	}
+	return global.RedisClient.Del(context.Background(), key).Err()
+}
+
+// MergeAnonCart 登录后把匿名购物车合并到用户购物车：相同商品数量相加（不超过上限和库存），
+// 合并后删除匿名购物车
+func (s *CartService) MergeAnonCart(userID int64, cartID string) error {
+	anonKey, _, err := cartKey(&CartOwner{CartID: cartID})
+	if err != nil {
+		return err
+	}
+	userKey, ttl, _ := cartKey(&CartOwner{UserID: userID})
+	ctx := context.Background()
+	anonValues, err := global.RedisClient.HGetAll(ctx, anonKey).Result()
+	if err != nil {
+		return err
+	}
+	if len(anonValues) == 0 {
+		return nil
+	}
+	userValues, err := global.RedisClient.HGetAll(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[int64]int, len(anonValues)+len(userValues))
+	var ids []int64
+	for _, values := range []map[string]string{userValues, anonValues} {
+		for field, value := range values {
+			bookID, err1 := strconv.ParseInt(field, 10, 64)
+			quantity, err2 := strconv.Atoi(value)
+			if err1 != nil || err2 != nil || quantity <= 0 {
+				continue
+			}
+			if _, ok := merged[bookID]; !ok {
+				ids = append(ids, bookID)
+			}
+			merged[bookID] += quantity
+		}
+	}
+	books, err := s.BookDB.GetBooksByIDs(ids)
+	if err != nil {
+		return err
+	}
+	stocks := make(map[int64]int, len(books))
+	for _, book := range books {
+		stocks[book.ID] = book.Stock
+	}
+
+	fields := make(map[string]interface{}, len(merged))
+	for bookID, quantity := range merged {
+		if len(fields) >= cartMaxItems {
+			break
+		}
+		stock, ok := stocks[bookID]
+		if !ok {
+			continue
+		}
+		quantity = min(quantity, cartMaxQuantity)
+		if stock > 0 {
+			quantity = min(quantity, stock)
+		}
+		fields[strconv.FormatInt(bookID, 10)] = quantity
+	}
+
+	pipe := global.RedisClient.TxPipeline()
+	if len(fields) > 0 {
+		pipe.HSet(ctx, userKey, fields)
+		pipe.Expire(ctx, userKey, ttl)
+	}
+	pipe.Del(ctx, anonKey)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Checkout 把购物车中可结算的商品下单，bookIDs 为空时结算全部；下单成功后从购物车移除这些商品
+func (s *CartService) Checkout(userID int64, bookIDs []int64) (*model.Order, error) {
+	cart, err := s.GetCart(&CartOwner{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[int64]bool, len(bookIDs))
+	for _, id := range bookIDs {
+		selected[id] = true
+	}
+
+	// 带上购物车展示的价格，期间如有调价会被 CreateOrder 拒绝，避免用户按旧价格确认却按新价格付款
+	req := &OrderRequest{UserID: userID}
+	var fields []string
+	for _, item := range cart.Items {
+		if len(selected) > 0 && !selected[item.BookID] {
+			continue
+		}
+		if !item.Available {
+			if len(selected) > 0 {
+				return nil, fmt.Errorf("《%s》已下架或库存不足", item.Title)
+			}
+			continue
+		}
+		req.Items = append(req.Items, OrderItems{
+			BookID:   item.BookID,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+		})
+		fields = append(fields, strconv.FormatInt(item.BookID, 10))
+	}
+	if len(req.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	order, err := s.OrderService.CreateOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	key, _, _ := cartKey(&CartOwner{UserID: userID})
+	global.RedisClient.HDel(context.Background(), key, fields...)
+	return order, nil
+}
+
+// setQuantity 校验图书状态和库存后写入数量
+func (s *CartService) setQuantity(key string, ttl time.Duration, bookID int64, quantity int) error {
+	if quantity > cartMaxQuantity {
+		return fmt.Errorf("单个商品最多购买%d件", cartMaxQuantity)
+	}
+	book, err := s.BookDB.GetBooksByID(bookID)
+	if err != nil {
+		return errors.New("图书不存在或已下架")
+	}
+	if book.Stock < quantity {
+		return fmt.Errorf("库存不足，当前库存%d件", book.Stock)
+	}
+	ctx := context.Background()
+	pipe := global.RedisClient.TxPipeline()
+	pipe.HSet(ctx, key, strconv.FormatInt(bookID, 10), quantity)
+	pipe.Expire(ctx, key, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// buildCart 根据图书表的最新数据组装购物车，已删除的图书也会展示为不可结算
+func (s *CartService) buildCart(quantities map[int64]int) (*Cart, error) {
+	cart := &Cart{Items: []*CartItem{}}
+	if len(quantities) == 0 {
+		return cart, nil
+	}
+	ids := make([]int64, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	books, err := s.BookDB.GetBooksByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[int64]*model.Book, len(books))
+	for _, book := range books {
+		found[book.ID] = book
+	}
+
+	for _, id := range ids {
+		quantity := quantities[id]
+		book, ok := found[id]
+		if !ok {
+			cart.Items = append(cart.Items, &CartItem{BookID: id, Title: "商品已失效", Quantity: quantity})
+			continue
+		}
+		price := discountedPrice(book)
+		item := &CartItem{
+			BookID:    book.ID,
+			Title:     book.Title,
+			Author:    book.Author,
+			CoverURL:  book.CoverURL,
+			ListPrice: book.Price,
+			Discount:  book.Discount,
+			Price:     price,
+			Stock:     book.Stock,
+			Quantity:  quantity,
+			Subtotal:  price * quantity,
+			Available: book.Status == 1 && book.Stock >= quantity,
+		}
+		cart.Items = append(cart.Items, item)
+		if item.Available {
+			cart.TotalQuantity += item.Quantity
+			cart.TotalAmount += item.Subtotal
+		}
+	}
+	return cart, nil
+}
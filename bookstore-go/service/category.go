@@ -13,6 +13,7 @@ func NewCategoryService(categoryDAO *repository.CategoryDAO) *CategoryService {
 	return &CategoryService{CategoryDAO: categoryDAO}
 }
 
+// GetAllCategories 店面分类列表，只返回已启用的分类
 func (s *CategoryService) GetAllCategories() ([]*model.Category, error) {
-	return s.CategoryDAO.GetAll()
+	return s.CategoryDAO.GetActive()
 }
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyLockTTL 请求处理中的占位记录有效期，进程在处理途中崩溃时，客户端最多等这么久就能重试
+const idempotencyLockTTL = time.Minute
+
+// IdempotencyRecord 幂等键对应的记录，Completed 为 false 表示第一次请求仍在处理中
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+// IdempotencyStore 在 Redis 中保存幂等键和第一次请求的响应
+type IdempotencyStore struct {
+	ttl time.Duration
+}
+
+// NewIdempotencyStore ttl 为响应保留的时长，超过后同一个键会被当作新请求
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{ttl: ttl}
+}
+
+// Fingerprint 请求指纹，同一个键必须对应相同的方法、路径和请求体
+func (s *IdempotencyStore) Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", method, path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin 尝试占用幂等键。返回 true 表示这是第一次请求，应继续处理；
+// 否则返回已有的记录，由调用方重放响应或报告冲突
+func (s *IdempotencyStore) Begin(scope, key, fingerprint string) (*IdempotencyRecord, bool, error) {
+	ctx := context.Background()
+	redisKey := idempotencyKey(scope, key)
+	placeholder, _ := json.Marshal(&IdempotencyRecord{Fingerprint: fingerprint})
+	acquired, err := global.RedisClient.SetNX(ctx, redisKey, placeholder, idempotencyLockTTL).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if acquired {
+		return nil, true, nil
+	}
+	value, err := global.RedisClient.Get(ctx, redisKey).Result()
+	if err == redis.Nil {
+		// 占位刚好过期，再抢一次
+		return s.Begin(scope, key, fingerprint)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, false, err
+	}
+	return &record, false, nil
+}
+
+// Complete 保存第一次请求的响应，窗口期内的重复请求直接返回它
+func (s *IdempotencyStore) Complete(scope, key string, record *IdempotencyRecord) error {
+	record.Completed = true
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return global.RedisClient.Set(context.Background(), idempotencyKey(scope, key), value, s.ttl).Err()
+}
+
+// Release 第一次请求失败（服务端错误）时释放幂等键，允许客户端用同一个键重试
+func (s *IdempotencyStore) Release(scope, key string) error {
+	return global.RedisClient.Del(context.Background(), idempotencyKey(scope, key)).Err()
+}
+
+// idempotencyKey 幂等键按用户隔离，键本身可能很长，只保存哈希
+func idempotencyKey(scope, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("idempotency:%s:%s", scope, hex.EncodeToString(sum[:]))
+}
@@ -5,33 +5,40 @@ import (
 	"bookstore-manager/model"
 	"bookstore-manager/mq"
 	"bookstore-manager/repository"
+	"bookstore-manager/utils/orderno"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type OrderService struct {
-	OrderDB *repository.OrderDAO
-	BookDB  *repository.BookDAO
+	OrderDB    *repository.OrderDAO
+	BookDB     *repository.BookDAO
+	CampaignDB *repository.SeckillDAO
 }
 
 func NewOrderService() *OrderService {
 	return &OrderService{
-		OrderDB: repository.NewOrderDAO(),
-		BookDB:  repository.NewBookDAO(),
+		OrderDB:    repository.NewOrderDAO(),
+		BookDB:     repository.NewBookDAO(),
+		CampaignDB: repository.NewSeckillDAO(),
 	}
 }
 
 // [修改] DTO 里的 ID 也要改成 int64
 type OrderRequest struct {
-	UserID int64        `json:"user_id,string"` // int -> int64
-	Items  []OrderItems `json:"items"`
+	UserID        int64        `json:"user_id,string"` // int -> int64
+	Items         []OrderItems `json:"items"`
+	ExpectedTotal int          `json:"expected_total"` // 客户端展示的应付总价，仅用于校验
 }
 
+// OrderItems 下单项，Price 是客户端展示的单价，仅用于校验，实际成交价由服务端计算
 type OrderItems struct {
 	BookID   int64 `json:"book_id,string"` // int -> int64
 	Quantity int   `json:"quantity"`
@@ -42,6 +49,7 @@ type OrderMessage struct {
 	UserID     int64 // int -> int64
 	Items      []OrderItems
 	OrderNo    string
+	CampaignID int64 // 秒杀活动ID，普通异步下单为 0
 	CreateTime int64
 }
 
@@ -50,39 +58,40 @@ func (o *OrderService) CreateOrder(req *OrderRequest) (*model.Order, error) {
 	if len(req.Items) == 0 {
 		return nil, errors.New("订单项不能为空")
 	}
+	// 同一本书出现多次时先合并，库存校验和定价都按合计数量计算
+	req.Items = mergeOrderItems(req.Items)
 	//1.判断库存是否充足
 	err := o.CheckStockAvailability(req)
 	if err != nil {
 		return nil, err
 	}
-	//2.生成订单号（下单成功）
-	orderNo := o.GenerateOrderNo()
-	var totalAmount int
-	var OrderItems []*model.OrderItem
-
-	for _, item := range req.Items {
-		subtotal := item.Price * item.Quantity
-		totalAmount += subtotal
-
-		OrderItems = append(OrderItems, &model.OrderItem{
-			BookID:   item.BookID,
-			Quantity: item.Quantity,
-			Price:    item.Price,
-			Subtotal: subtotal,
-		})
+	//2.按当前价格和折扣定价，并与客户端看到的总价比对
+	OrderItems, totalAmount, err := o.priceOrderItems(req.Items)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClientTotal(req, totalAmount); err != nil {
+		return nil, err
 	}
+	//3.生成订单号（下单成功）
+	orderNo := o.GenerateOrderNo(orderno.ChannelWeb)
 	//支付
 	order := &model.Order{
 		UserID:      req.UserID,
 		OrderNo:     orderNo,
 		TotalAmount: totalAmount,
-		Status:      0,
+		Status:      model.OrderStatusPending,
 		IsPaid:      false,
 	}
+	//4.落库并预占库存
 	err = o.OrderDB.CreateOrderWithItems(order, OrderItems)
 	if err != nil {
 		return nil, err
 	}
+	for _, item := range OrderItems {
+		adjustStockCache(item.BookID, -item.Quantity)
+	}
+	scheduleOrderTimeout(order.ID)
 	fullOrder, err := o.OrderDB.GetOrderByID(order.ID)
 	if err != nil {
 		return nil, err
@@ -109,9 +118,9 @@ func (o *OrderService) CheckStockAvailability(req *OrderRequest) error {
 	return nil
 }
 
-func (o *OrderService) GenerateOrderNo() string {
-	orderNo := fmt.Sprintf("ORD%d", time.Now().UnixNano())
-	return orderNo
+// GenerateOrderNo 生成订单号，格式见 utils/orderno
+func (o *OrderService) GenerateOrderNo(channel string) string {
+	return orderno.Generate(channel)
 }
 
 // [修改] 参数 userID 改为 int64
@@ -119,16 +128,23 @@ func (o *OrderService) GetUserOrders(userID int64, page, pageSize int) ([]*model
 	return o.OrderDB.GetUserOrders(userID, page, pageSize)
 }
 
-// [修改] 参数 orderID 改为 int64
-func (o *OrderService) PayOrders(orderID int64) error {
-	order, _ := o.OrderDB.GetOrderByID(orderID)
-	if order.IsPaid {
-		return errors.New("订单已支付")
+// markOrderPaid 支付渠道确认收款后把订单标记为已支付。库存在下单时已预占，
+// 这里只累加销量；订单已不是待支付（如超时取消）时返回状态错误，由调用方退款
+func (o *OrderService) markOrderPaid(orderID int64, paymentNo string) error {
+	order, err := o.OrderDB.GetOrderByID(orderID)
+	if err != nil {
+		return errors.New("订单不存在")
 	}
-	err := o.OrderDB.UpdateOrderStatus(order)
+	err = o.OrderDB.PayOrder(order, systemTransition("支付成功，支付单 "+paymentNo))
 	if err != nil {
 		return err
 	}
+	// 历史订单在支付时才扣库存，同步到 Redis
+	if !order.StockReserved {
+		for _, item := range order.OrderItems {
+			adjustStockCache(item.BookID, -item.Quantity)
+		}
+	}
 
 	// [新增] 支付成功后，更新销量排行榜 (即使失败也不影响支付主流程，仅打日志)
 	go func() {
@@ -156,7 +172,50 @@ func (o *OrderService) GetOrderByID(orderID int64) error {
 
 // [修改] 参数 orderID 改为 int64
 func (o *OrderService) GetOrder(orderID int64) (*model.Order, error) {
-	return o.OrderDB.GetOrderByID(orderID)
+	return o.OrderDB.GetOrderDetail(orderID)
+}
+
+// GetOrderByRef 按订单ID或订单号查询订单详情
+func (o *OrderService) GetOrderByRef(ref string) (*model.Order, error) {
+	orderID, err := o.resolveOrderRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	return o.GetOrder(orderID)
+}
+
+// GetUserOrderByRef 按订单ID或订单号查询用户自己的订单详情
+func (o *OrderService) GetUserOrderByRef(userID int64, ref string) (*model.Order, error) {
+	orderID, err := o.resolveOrderRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	return o.GetUserOrder(userID, orderID)
+}
+
+// resolveOrderRef 订单ID是不超过19位的数字，订单号更长（或是旧版的 ORD 前缀），
+// 无法按ID解析时按订单号校验后查询
+func (o *OrderService) resolveOrderRef(ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+	if err := orderno.Validate(ref); err != nil {
+		return 0, err
+	}
+	order, err := o.OrderDB.GetOrderByNo(ref)
+	if err != nil {
+		return 0, errors.New("订单不存在")
+	}
+	return order.ID, nil
+}
+
+// GetUserOrder 用户查看自己的订单详情（含状态变更记录）
+func (o *OrderService) GetUserOrder(userID, orderID int64) (*model.Order, error) {
+	order, err := o.OrderDB.GetOrderDetail(orderID)
+	if err != nil || order.UserID != userID {
+		return nil, errors.New("订单不存在")
+	}
+	return order, nil
 }
 
 // [修改] 参数 userID, orderID 改为 int64
@@ -168,70 +227,212 @@ func (o *OrderService) CancelOrder(userID, orderID int64) error {
 	if order.UserID != userID {
 		return errors.New("无权操作此订单")
 	}
-	if order.Status != 0 {
+	if order.Status != model.OrderStatusPending {
 		return errors.New("只有未支付的订单才可以取消")
 	}
-	return o.OrderDB.CancelOrder(orderID)
+	return o.cancelOrder(order, &repository.OrderTransition{
+		ActorType: model.OrderActorUser,
+		ActorID:   userID,
+		Reason:    "用户取消",
+	})
+}
+
+// cancelOrder 取消订单并把归还的库存同步到 Redis，秒杀订单还要归还活动库存和用户的限购名额
+func (o *OrderService) cancelOrder(order *model.Order, t *repository.OrderTransition) error {
+	if err := o.OrderDB.CancelOrder(order, t); err != nil {
+		return err
+	}
+	var quantity int
+	for _, item := range order.OrderItems {
+		if order.StockReserved {
+			adjustStockCache(item.BookID, item.Quantity)
+		}
+		quantity += item.Quantity
+	}
+	if order.CampaignID != 0 {
+		releaseSeckillQuota(order.CampaignID, order.UserID, quantity)
+	}
+	return nil
+}
+
+// ListOrders 后台订单列表
+func (o *OrderService) ListOrders(filter *repository.OrderFilter, page, pageSize int) ([]*model.Order, int64, error) {
+	return o.OrderDB.ListOrders(filter, page, pageSize)
+}
+
+// AdminCancelOrder 后台取消订单，不校验订单归属
+func (o *OrderService) AdminCancelOrder(operatorID, orderID int64, reason string) error {
+	order, err := o.OrderDB.GetOrderByID(orderID)
+	if err != nil {
+		return errors.New("订单不存在")
+	}
+	if order.Status != model.OrderStatusPending {
+		return errors.New("只有未支付的订单才可以取消")
+	}
+	if reason == "" {
+		reason = "后台取消"
+	}
+	return o.cancelOrder(order, &repository.OrderTransition{
+		ActorType: model.OrderActorAdmin,
+		ActorID:   operatorID,
+		Reason:    reason,
+	})
+}
+
+// fulfilmentStatuses 后台可以手动推进的履约状态
+var fulfilmentStatuses = map[int]bool{
+	model.OrderStatusPacked:    true,
+	model.OrderStatusShipped:   true,
+	model.OrderStatusDelivered: true,
+	model.OrderStatusCompleted: true,
+}
+
+// UpdateFulfilment 后台推进订单履约状态（打包、发货、送达、完成），是否允许由状态表决定。
+// 退款中的订单只能由退款流程结束（退款完成或回到申请前的状态），这里一律拒绝
+func (o *OrderService) UpdateFulfilment(operatorID, orderID int64, status int, reason string) (*model.Order, error) {
+	if !fulfilmentStatuses[status] {
+		return nil, errors.New("只能修改为 packed、shipped、delivered 或 completed")
+	}
+	order, err := o.OrderDB.GetOrderByID(orderID)
+	if err != nil {
+		return nil, errors.New("订单不存在")
+	}
+	if order.Status == model.OrderStatusRefunding {
+		return nil, &repository.OrderTransitionError{From: order.Status, To: status}
+	}
+	err = o.OrderDB.TransitionOrder(order, &repository.OrderTransition{
+		To:        status,
+		ActorType: model.OrderActorAdmin,
+		ActorID:   operatorID,
+		Reason:    reason,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return o.OrderDB.GetOrderDetail(orderID)
+}
+
+// ConfirmReceipt 用户确认收货，已送达的订单变为已完成
+func (o *OrderService) ConfirmReceipt(userID, orderID int64) error {
+	order, err := o.OrderDB.GetOrderByID(orderID)
+	if err != nil || order.UserID != userID {
+		return errors.New("订单不存在")
+	}
+	if order.Status != model.OrderStatusDelivered {
+		return errors.New("订单尚未送达，不能确认收货")
+	}
+	return o.OrderDB.TransitionOrder(order, &repository.OrderTransition{
+		To:        model.OrderStatusCompleted,
+		ActorType: model.OrderActorUser,
+		ActorID:   userID,
+		Reason:    "用户确认收货",
+	}, nil)
+}
+
+// systemTransition 系统自动执行的状态变更
+func systemTransition(reason string) *repository.OrderTransition {
+	return &repository.OrderTransition{ActorType: model.OrderActorSystem, Reason: reason}
 }
 
 func (o *OrderService) CreateOrderAsync(req *OrderRequest) (string, error) {
 	if len(req.Items) == 0 {
 		return "", errors.New("订单项不能为空")
 	}
-	targetBookID := req.Items[0].BookID
-	buyNum := req.Items[0].Quantity
+	items := mergeOrderItems(req.Items)
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return "", errors.New("购买数量必须大于0")
+		}
+	}
 
-	stockKey := fmt.Sprintf("stock:%d", targetBookID)
-	result, err := global.RedisClient.DecrBy(context.Background(), stockKey, int64(buyNum)).Result()
+	// 所有图书一起预占，任意一本不足则整单失败，不会出现只扣了部分库存的情况
+	reserved, err := reserveStockCache(items)
 	if err != nil {
 		return "", errors.New("系统繁忙 (Redis Error)")
 	}
-
-	if result < 0 {
-		global.RedisClient.IncrBy(context.Background(), stockKey, int64(buyNum))
+	if !reserved {
 		return "", errors.New("库存不足，被抢光啦！")
 	}
 
-	orderNo := o.GenerateOrderNo()
+	orderNo := o.GenerateOrderNo(orderno.ChannelAsync)
 
-	msgObj := OrderMessage{
+	msgObj := &OrderMessage{
 		UserID:     req.UserID,
 		OrderNo:    orderNo,
-		Items:      req.Items,
+		Items:      items,
 		CreateTime: time.Now().Unix(),
 	}
-
-	msgBytes, _ := json.Marshal(msgObj)
-	err = mq.SendMessage("order.seckill", string(msgBytes))
-	if err != nil {
+	if err := publishOrderMessage(msgObj); err != nil {
+		releaseOrderStock(msgObj)
 		return "", errors.New("系统繁忙，请稍后再试")
 	}
 	return orderNo, nil
 }
 
-func (o *OrderService) CreateOrderInDB(msg *OrderMessage) error {
-	var totalAmount int
-	var orderItems []*model.OrderItem
+// publishOrderMessage 把异步下单消息交给 order.seckill 消费者落库
+func publishOrderMessage(msg *OrderMessage) error {
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return mq.SendMessage("order.seckill", string(msgBytes))
+}
 
-	for _, item := range msg.Items {
-		subtotal := item.Price * item.Quantity
-		totalAmount += subtotal
+// CreateOrderInDB 消费异步下单消息：按服务端价格定价，在同一个事务中预占数据库库存并写入订单。
+// 消息可能被重复投递，订单号已经落库时直接返回成功
+func (o *OrderService) CreateOrderInDB(msg *OrderMessage) error {
+	if _, err := o.OrderDB.GetOrderByNo(msg.OrderNo); err == nil {
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
 
-		orderItems = append(orderItems, &model.OrderItem{
-			BookID:   item.BookID,
-			Quantity: item.Quantity,
-			Price:    item.Price,
-			Subtotal: subtotal,
-		})
+	// 消息里的价格来自客户端，入库前按当前价格（秒杀订单按活动价）重新计算
+	var orderItems []*model.OrderItem
+	var totalAmount int
+	var err error
+	if msg.CampaignID != 0 {
+		orderItems, totalAmount, err = o.priceSeckillItems(msg)
+	} else {
+		orderItems, totalAmount, err = o.priceOrderItems(msg.Items)
+	}
+	if err != nil {
+		return err
 	}
 
 	order := &model.Order{
 		UserID:      msg.UserID,
 		OrderNo:     msg.OrderNo,
 		TotalAmount: totalAmount,
-		Status:      0,
+		Status:      model.OrderStatusPending,
 		IsPaid:      false,
+		CampaignID:  msg.CampaignID,
 	}
 
-	return o.OrderDB.CreateOrderWithItems(order, orderItems)
+	// Redis 库存在抢购入口已经扣过，这里只预占数据库库存；数据库库存不足时把 Redis 的扣减还回去。
+	// 秒杀订单扣的是活动库存，stock:<id> 在落库成功后才同步
+	err = o.OrderDB.CreateOrderWithItems(order, orderItems)
+	if errors.Is(err, repository.ErrStockInsufficient) {
+		if releaseErr := o.AbandonOrderMessage(msg, "库存不足"); releaseErr != nil {
+			global.Logger.Error("归还预占库存失败", zap.String("orderNo", msg.OrderNo), zap.Error(releaseErr))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if msg.CampaignID != 0 {
+		for _, item := range orderItems {
+			adjustStockCache(item.BookID, -item.Quantity)
+		}
+	}
+	scheduleOrderTimeout(order.ID)
+	return nil
+}
+
+func totalQuantity(items []OrderItems) int {
+	var total int
+	for _, item := range items {
+		total += item.Quantity
+	}
+	return total
 }
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// orderMessageMaxAttempts 异步下单消息因数据库等临时故障落库失败时最多处理的次数，
+	// 用尽后归还库存并转入死信队列
+	orderMessageMaxAttempts = 5
+	// orderMessageStateTTL 重试计数和归还标记的保留时长
+	orderMessageStateTTL = 24 * time.Hour
+)
+
+func orderMessageAttemptsKey(orderNo string) string {
+	return "order_msg_attempts:" + orderNo
+}
+
+func orderMessageReleasedKey(orderNo string) string {
+	return "order_msg_released:" + orderNo
+}
+
+// RetryOrderMessage 记录一次落库失败，返回是否还可以重新投递。Redis 不可用时无法计数，继续重试
+func (o *OrderService) RetryOrderMessage(msg *OrderMessage) bool {
+	attempts, err := incrWithWindowScript.Run(context.Background(), global.RedisClient,
+		[]string{orderMessageAttemptsKey(msg.OrderNo)}, int64(orderMessageStateTTL.Seconds())).Int64()
+	if err != nil {
+		global.Logger.Error("记录下单消息重试次数失败", zap.String("orderNo", msg.OrderNo), zap.Error(err))
+		return true
+	}
+	return attempts < orderMessageMaxAttempts
+}
+
+// AbandonOrderMessage 异步下单最终失败（库存不足或重试用尽）时归还入口处在 Redis 中扣减的库存，
+// 秒杀订单同时归还限购名额并记录失败原因。同一个订单号只归还一次；
+// 订单其实已经落库（事务提交后、确认消息前进程退出）时不归还
+func (o *OrderService) AbandonOrderMessage(msg *OrderMessage, reason string) error {
+	if _, err := o.OrderDB.GetOrderByNo(msg.OrderNo); err == nil {
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	first, err := global.RedisClient.SetNX(context.Background(),
+		orderMessageReleasedKey(msg.OrderNo), 1, orderMessageStateTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !first {
+		return nil
+	}
+	releaseOrderStock(msg)
+	if msg.CampaignID != 0 {
+		markSeckillFailed(msg.OrderNo, reason)
+	}
+	return nil
+}
+
+// releaseOrderStock 归还异步下单入口在 Redis 中扣减的库存，秒杀订单归还的是活动库存和限购名额
+func releaseOrderStock(msg *OrderMessage) {
+	if msg.CampaignID != 0 {
+		releaseSeckillQuota(msg.CampaignID, msg.UserID, totalQuantity(msg.Items))
+		return
+	}
+	for _, item := range mergeOrderItems(msg.Items) {
+		adjustStockCache(item.BookID, item.Quantity)
+	}
+}
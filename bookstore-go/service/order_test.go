@@ -0,0 +1,39 @@
+package service
+
+import (
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"bookstore-manager/utils/testenv"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUpdateFulfilmentRejectsRefundingOrder(t *testing.T) {
+	db := testenv.MySQL(t, &model.Order{}, &model.OrderItem{}, &model.OrderStatusHistory{})
+	order := &model.Order{
+		UserID:  1,
+		OrderNo: fmt.Sprintf("TEST%d", time.Now().UnixNano()),
+		Status:  model.OrderStatusRefunding,
+		IsPaid:  true,
+	}
+	if err := db.Create(order).Error; err != nil {
+		t.Fatalf("创建订单失败: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Where("order_id = ?", order.ID).Delete(&model.OrderStatusHistory{})
+		db.Unscoped().Delete(order)
+	})
+
+	_, err := NewOrderService().UpdateFulfilment(1, order.ID, model.OrderStatusCompleted, "")
+	var transitionErr *repository.OrderTransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("err = %v, want OrderTransitionError", err)
+	}
+	var status int
+	db.Model(&model.Order{}).Where("id = ?", order.ID).Pluck("status", &status)
+	if status != model.OrderStatusRefunding {
+		t.Errorf("status = %d, want refunding", status)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/mq"
+	"bookstore-manager/repository"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var errOrderExpired = errors.New("订单已超时取消，请重新下单")
+
+// orderPaymentTimeout 下单后的支付期限
+func orderPaymentTimeout() time.Duration {
+	if n := config.AppConfig.Order.PaymentTimeoutMinutes; n > 0 {
+		return time.Duration(n) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// orderExpired 订单是否已过支付期限
+func orderExpired(order *model.Order) bool {
+	return time.Since(order.CreatedAt) >= orderPaymentTimeout()
+}
+
+// scheduleOrderTimeout 下单成功后投递延迟消息，到期仍未支付由 CancelExpiredOrder 取消。
+// 延迟队列只检查头部消息是否过期，所有消息统一使用支付期限作为延迟，不能按剩余时间投递。
+// 投递失败只记录日志，用户支付时仍会校验期限
+func scheduleOrderTimeout(orderID int64) {
+	if err := mq.SendDelayedMessage(strconv.FormatInt(orderID, 10), orderPaymentTimeout()); err != nil {
+		global.Logger.Error("投递订单超时消息失败", zap.Error(err), zap.Int64("orderID", orderID))
+	}
+}
+
+// CancelExpiredOrder 处理订单超时消息：取消仍未支付的订单并归还库存（含 Redis 中的库存计数）。
+// 消息可能重复投递，订单已支付、已取消或已不存在时直接返回 nil
+func (o *OrderService) CancelExpiredOrder(orderID int64) error {
+	order, err := o.OrderDB.GetOrderByID(orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if order.Status != model.OrderStatusPending {
+		return nil
+	}
+	// 支付期限被调长后，按旧期限投递的消息会提前到达，按统一的延迟重新投递；
+	// 订单最多晚一个周期被取消，期间用户支付仍按新期限校验
+	if !orderExpired(order) {
+		scheduleOrderTimeout(order.ID)
+		return nil
+	}
+	err = o.cancelOrder(order, systemTransition("支付超时"))
+	if errors.Is(err, repository.ErrOrderStateChanged) {
+		// 取消前一刻用户完成了支付或手动取消
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	global.Logger.Info("订单超时未支付，已自动取消", zap.String("orderNo", order.OrderNo))
+	return nil
+}
+
+// cancelIfExpired 超时消息可能还没被处理，发起支付前再校验一次支付期限，已超时则直接取消
+func (o *OrderService) cancelIfExpired(order *model.Order) error {
+	if order.Status != model.OrderStatusPending || !orderExpired(order) {
+		return nil
+	}
+	if err := o.cancelOrder(order, systemTransition("支付超时")); err != nil && !errors.Is(err, repository.ErrOrderStateChanged) {
+		global.Logger.Error("取消超时订单失败", zap.Error(err), zap.Int64("orderID", order.ID))
+	}
+	return errOrderExpired
+}
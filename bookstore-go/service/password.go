@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bookstore-manager/config"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCost 读取配置中的 bcrypt 成本，超出范围时使用默认值
+func passwordCost() int {
+	cost := config.AppConfig.Security.PasswordCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// hashPassword 使用 bcrypt 生成密码哈希，bcrypt 会为每个密码生成随机盐并写入哈希串中
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// isBcryptHash 判断数据库中存的是否为 bcrypt 哈希，早期数据是 base64 编码的明文
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// checkPassword 校验密码。needsRehash 为 true 表示密码正确但存储格式已过时
+// （旧的 base64 数据，或 bcrypt 成本与当前配置不一致），调用方应在登录成功后重新哈希
+func checkPassword(input, stored string) (ok bool, needsRehash bool) {
+	if !isBcryptHash(stored) {
+		legacy := base64.StdEncoding.EncodeToString([]byte(input))
+		if subtle.ConstantTimeCompare([]byte(legacy), []byte(stored)) != 1 {
+			return false, false
+		}
+		return true, true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(input)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost != passwordCost()
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash 用户不存在时用来做一次等价的 bcrypt 比较，避免通过响应时间判断用户名是否存在
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		hash, err := bcrypt.GenerateFromPassword([]byte("bookstore-dummy-password"), passwordCost())
+		if err == nil {
+			dummyHash = string(hash)
+		}
+	})
+	return dummyHash
+}
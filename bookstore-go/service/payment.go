@@ -0,0 +1,241 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/payment"
+	"bookstore-manager/repository"
+	"bookstore-manager/utils/snowflake"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownProvider 回调地址中的支付渠道未启用
+	ErrUnknownProvider = errors.New("未启用该支付渠道")
+	errPaymentNotFound = errors.New("支付单不存在")
+)
+
+type PaymentService struct {
+	PaymentDB    *repository.PaymentDAO
+	OrderDB      *repository.OrderDAO
+	OrderService *OrderService
+}
+
+func NewPaymentService() *PaymentService {
+	return &PaymentService{
+		PaymentDB:    repository.NewPaymentDAO(),
+		OrderDB:      repository.NewOrderDAO(),
+		OrderService: NewOrderService(),
+	}
+}
+
+// CreatePayment 为待支付订单发起支付，返回收银台地址。订单已有未完成的支付单时直接复用，
+// 避免同一订单在渠道侧产生多笔可以付款的交易
+func (s *PaymentService) CreatePayment(userID, orderID int64) (*model.Payment, error) {
+	order, err := s.OrderDB.GetOrderByID(orderID)
+	if err != nil || order.UserID != userID {
+		return nil, errors.New("订单不存在")
+	}
+	if order.IsPaid {
+		return nil, errors.New("订单已支付")
+	}
+	if order.Status != model.OrderStatusPending {
+		return nil, errors.New("订单当前状态不能支付")
+	}
+	if err := s.OrderService.cancelIfExpired(order); err != nil {
+		return nil, err
+	}
+	if payment.Default == nil {
+		return nil, errors.New("支付服务未初始化")
+	}
+
+	if pending, err := s.PaymentDB.GetPendingPayment(order.ID); err == nil && pending.Provider == payment.Default.Name() {
+		return pending, nil
+	}
+
+	pay := &model.Payment{
+		PaymentNo: fmt.Sprintf("PAY%d", snowflake.GenID()),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Provider:  payment.Default.Name(),
+		Amount:    order.TotalAmount,
+		Status:    model.PaymentStatusPending,
+	}
+	intent, err := payment.Default.CreateIntent(&payment.IntentRequest{
+		PaymentNo: pay.PaymentNo,
+		OrderNo:   order.OrderNo,
+		Subject:   "Bookstore 订单 " + order.OrderNo,
+		Amount:    pay.Amount,
+		NotifyURL: payment.NotifyURL(payment.Default.Name()),
+	})
+	if err != nil {
+		global.Logger.Error("创建支付单失败", zap.Error(err), zap.String("orderNo", order.OrderNo))
+		return nil, errors.New("发起支付失败，请稍后再试")
+	}
+	pay.TradeNo = intent.TradeNo
+	pay.PayURL = intent.PayURL
+	if err := s.PaymentDB.CreatePayment(pay); err != nil {
+		return nil, err
+	}
+	return pay, nil
+}
+
+// GetPayment 查询支付单，仍在等待付款时主动向渠道查询一次，用于弥补丢失的回调
+func (s *PaymentService) GetPayment(userID int64, paymentNo string) (*model.Payment, error) {
+	pay, err := s.PaymentDB.GetPaymentByNo(paymentNo)
+	if err != nil || pay.UserID != userID {
+		return nil, errPaymentNotFound
+	}
+	if pay.Status != model.PaymentStatusPending {
+		return pay, nil
+	}
+	provider, ok := payment.Get(pay.Provider)
+	if !ok {
+		return pay, nil
+	}
+	result, err := provider.Query(pay.PaymentNo)
+	if err != nil {
+		global.Logger.Warn("查询支付结果失败", zap.Error(err), zap.String("paymentNo", pay.PaymentNo))
+		return pay, nil
+	}
+	if err := s.applyPaymentResult(provider, pay, result.Status, result.TradeNo, result.Amount); err != nil {
+		return nil, err
+	}
+	return s.PaymentDB.GetPaymentByNo(paymentNo)
+}
+
+// HandleCallback 处理支付渠道的回调。签名不通过返回 payment.ErrInvalidSignature；
+// 同一个回调可能到达多次，重复的回调不会产生副作用
+func (s *PaymentService) HandleCallback(providerName string, header http.Header, body []byte) error {
+	provider, ok := payment.Get(providerName)
+	if !ok {
+		return ErrUnknownProvider
+	}
+	event, err := provider.VerifyCallback(header, body)
+	if err != nil {
+		return err
+	}
+	pay, err := s.PaymentDB.GetPaymentByNo(event.PaymentNo)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 不是本系统的支付单，返回成功让渠道停止重试
+		global.Logger.Warn("收到未知支付单的回调", zap.String("paymentNo", event.PaymentNo))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pay.Provider != provider.Name() {
+		return ErrUnknownProvider
+	}
+
+	switch event.Type {
+	case payment.EventPayment:
+		return s.applyPaymentResult(provider, pay, event.Status, event.TradeNo, event.Amount)
+	case payment.EventRefund:
+		return NewRefundService().HandleRefundResult(event.RefundNo, event.Status)
+	default:
+		global.Logger.Info("忽略支付回调", zap.String("type", event.Type), zap.String("paymentNo", event.PaymentNo))
+		return nil
+	}
+}
+
+// applyPaymentResult 把渠道确认的结果写入支付单，付款成功时驱动订单变为已支付
+func (s *PaymentService) applyPaymentResult(provider payment.PaymentProvider, pay *model.Payment, status, tradeNo string, amount int) error {
+	switch {
+	case pay.Status == model.PaymentStatusSucceeded && status == payment.StatusSucceeded:
+		// 重复的成功回调；上一次可能只记录了付款而更新订单失败，这里补上
+		return s.settleOrder(provider, pay)
+	case pay.Status != model.PaymentStatusPending:
+		return nil
+	}
+	switch status {
+	case payment.StatusSucceeded:
+		if amount != pay.Amount {
+			global.Logger.Error("支付金额与支付单不一致", zap.String("paymentNo", pay.PaymentNo),
+				zap.Int("expected", pay.Amount), zap.Int("actual", amount))
+			return errors.New("支付金额不一致")
+		}
+		now := time.Now()
+		ok, err := s.PaymentDB.UpdatePaymentStatus(pay.ID, model.PaymentStatusPending, model.PaymentStatusSucceeded,
+			map[string]interface{}{"trade_no": tradeNo, "paid_at": &now})
+		if err != nil || !ok {
+			return err
+		}
+		return s.settleOrder(provider, pay)
+	case payment.StatusFailed:
+		_, err := s.PaymentDB.UpdatePaymentStatus(pay.ID, model.PaymentStatusPending, model.PaymentStatusFailed,
+			map[string]interface{}{"trade_no": tradeNo})
+		return err
+	default:
+		return nil
+	}
+}
+
+// settleOrder 付款成功后把订单标记为已支付。订单在付款到账前已超时取消，
+// 或已由另一笔支付单支付时，原路退回这笔付款
+func (s *PaymentService) settleOrder(provider payment.PaymentProvider, pay *model.Payment) error {
+	err := s.OrderService.markOrderPaid(pay.OrderID, pay.PaymentNo)
+	var transitionErr *repository.OrderTransitionError
+	if !errors.As(err, &transitionErr) && !errors.Is(err, repository.ErrOrderStateChanged) {
+		return err
+	}
+	order, err := s.OrderDB.GetOrderByID(pay.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.IsPaid {
+		paid, err := s.PaymentDB.CountPayments(pay.OrderID, model.PaymentStatusSucceeded)
+		if err != nil {
+			return err
+		}
+		if paid <= 1 {
+			// 订单就是这笔付款支付的（重复回调）
+			return nil
+		}
+	}
+	return s.refundOrphanPayment(provider, pay)
+}
+
+// refundOrphanPayment 订单已无法支付时全额退回这笔付款
+func (s *PaymentService) refundOrphanPayment(provider payment.PaymentProvider, pay *model.Payment) error {
+	global.Logger.Warn("订单已不是待支付状态，自动退回付款", zap.String("paymentNo", pay.PaymentNo), zap.Int64("orderID", pay.OrderID))
+	_, err := provider.Refund(&payment.RefundRequest{
+		PaymentNo: pay.PaymentNo,
+		RefundNo:  "R" + pay.PaymentNo,
+		Amount:    pay.Amount,
+		Reason:    "订单已关闭",
+	})
+	if err != nil {
+		return fmt.Errorf("自动退款失败: %w", err)
+	}
+	_, err = s.PaymentDB.UpdatePaymentStatus(pay.ID, model.PaymentStatusSucceeded, model.PaymentStatusRefunded,
+		map[string]interface{}{"refunded_amount": pay.Amount})
+	return err
+}
+
+// MockPay 模拟收银台：success、fail 或 timeout，仅在使用 mock 渠道时可用
+func (s *PaymentService) MockPay(paymentNo, outcome string) error {
+	mock, ok := payment.Default.(*payment.MockProvider)
+	if !ok {
+		return ErrUnknownProvider
+	}
+	return mock.Simulate(paymentNo, outcome)
+}
+
+// GetMockPayment 模拟收银台展示的支付单信息
+func (s *PaymentService) GetMockPayment(paymentNo string) (*model.Payment, error) {
+	if _, ok := payment.Default.(*payment.MockProvider); !ok {
+		return nil, ErrUnknownProvider
+	}
+	pay, err := s.PaymentDB.GetPaymentByNo(paymentNo)
+	if err != nil {
+		return nil, errPaymentNotFound
+	}
+	return pay, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bookstore-manager/model"
+	"errors"
+	"fmt"
+)
+
+// orderPriceTolerance 客户端计算的总价与服务端不一致时允许的误差（元），超过则拒绝下单
+const orderPriceTolerance = 1
+
+// PriceChangedError 客户端展示的价格与当前价格不一致，通常是下单前图书调价或折扣变化，
+// 控制器据此返回 409 并附带最新总价，前端刷新后重新下单
+type PriceChangedError struct {
+	ClientTotal int
+	ServerTotal int
+}
+
+func (e *PriceChangedError) Error() string {
+	return fmt.Sprintf("商品价格已变动，当前应付%d元，请确认后重新下单", e.ServerTotal)
+}
+
+// discountedPrice 折后单价，折扣为减免的百分比，结果向下取整（与前端展示一致）
+func discountedPrice(book *model.Book) int {
+	if book.Discount <= 0 || book.Discount > 100 {
+		return book.Price
+	}
+	return book.Price * (100 - book.Discount) / 100
+}
+
+// mergeOrderItems 合并同一本书的多个下单项，保持首次出现的顺序。
+// 不合并时每一项单独校验库存，合计数量超过库存也能通过
+func mergeOrderItems(items []OrderItems) []OrderItems {
+	merged := make([]OrderItems, 0, len(items))
+	index := make(map[int64]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.BookID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.BookID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// priceOrderItems 按图书当前的价格和折扣为每一项定价，不使用客户端传入的价格
+func (o *OrderService) priceOrderItems(items []OrderItems) ([]*model.OrderItem, int, error) {
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, 0, errors.New("购买数量必须大于0")
+		}
+		ids = append(ids, item.BookID)
+	}
+	books, err := o.BookDB.GetBooksByIDs(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	found := make(map[int64]*model.Book, len(books))
+	for _, book := range books {
+		found[book.ID] = book
+	}
+
+	var totalAmount int
+	orderItems := make([]*model.OrderItem, 0, len(items))
+	for _, item := range items {
+		book, ok := found[item.BookID]
+		if !ok {
+			return nil, 0, errors.New("图书不存在")
+		}
+		price := discountedPrice(book)
+		subtotal := price * item.Quantity
+		totalAmount += subtotal
+		orderItems = append(orderItems, &model.OrderItem{
+			BookID:    item.BookID,
+			Quantity:  item.Quantity,
+			ListPrice: book.Price,
+			Discount:  book.Discount,
+			Price:     price,
+			Subtotal:  subtotal,
+		})
+	}
+	return orderItems, totalAmount, nil
+}
+
+// checkClientTotal 校验客户端看到的总价。优先使用 expected_total，未传时用各项的 price 汇总；
+// 两者都没有（旧版客户端）时不校验
+func checkClientTotal(req *OrderRequest, serverTotal int) error {
+	clientTotal := req.ExpectedTotal
+	if clientTotal <= 0 {
+		for _, item := range req.Items {
+			if item.Price <= 0 {
+				return nil
+			}
+			clientTotal += item.Price * item.Quantity
+		}
+	}
+	diff := clientTotal - serverTotal
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > orderPriceTolerance {
+		return &PriceChangedError{ClientTotal: clientTotal, ServerTotal: serverTotal}
+	}
+	return nil
+}
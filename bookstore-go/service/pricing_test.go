@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bookstore-manager/model"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiscountedPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    int
+		discount int
+		want     int
+	}{
+		{"no discount", 59, 0, 59},
+		{"20 percent off", 59, 20, 47}, // 47.2 向下取整
+		{"half price", 68, 50, 34},
+		{"free", 30, 100, 0},
+		{"negative discount ignored", 59, -10, 59},
+		{"discount over 100 ignored", 59, 120, 59},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := &model.Book{Price: tt.price, Discount: tt.discount}
+			if got := discountedPrice(book); got != tt.want {
+				t.Errorf("discountedPrice(%d, %d) = %d, want %d", tt.price, tt.discount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckClientTotal(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *OrderRequest
+		serverTotal int
+		wantChanged bool
+	}{
+		{
+			name:        "expected total matches",
+			req:         &OrderRequest{ExpectedTotal: 100},
+			serverTotal: 100,
+		},
+		{
+			name:        "expected total within tolerance",
+			req:         &OrderRequest{ExpectedTotal: 99},
+			serverTotal: 100,
+		},
+		{
+			name:        "expected total changed",
+			req:         &OrderRequest{ExpectedTotal: 90},
+			serverTotal: 100,
+			wantChanged: true,
+		},
+		{
+			name: "item prices match",
+			req: &OrderRequest{Items: []OrderItems{
+				{BookID: 1, Quantity: 2, Price: 30},
+				{BookID: 2, Quantity: 1, Price: 40},
+			}},
+			serverTotal: 100,
+		},
+		{
+			name: "item prices changed",
+			req: &OrderRequest{Items: []OrderItems{
+				{BookID: 1, Quantity: 2, Price: 20},
+			}},
+			serverTotal: 60,
+			wantChanged: true,
+		},
+		{
+			name: "expected total takes precedence over item prices",
+			req: &OrderRequest{ExpectedTotal: 60, Items: []OrderItems{
+				{BookID: 1, Quantity: 2, Price: 20},
+			}},
+			serverTotal: 60,
+		},
+		{
+			name: "legacy client without prices is not checked",
+			req: &OrderRequest{Items: []OrderItems{
+				{BookID: 1, Quantity: 2},
+			}},
+			serverTotal: 60,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClientTotal(tt.req, tt.serverTotal)
+			var changed *PriceChangedError
+			if got := errors.As(err, &changed); got != tt.wantChanged {
+				t.Fatalf("checkClientTotal() error = %v, wantChanged %v", err, tt.wantChanged)
+			}
+			if changed != nil && changed.ServerTotal != tt.serverTotal {
+				t.Errorf("ServerTotal = %d, want %d", changed.ServerTotal, tt.serverTotal)
+			}
+		})
+	}
+}
+
+func TestMergeOrderItems(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []OrderItems
+		want  []OrderItems
+	}{
+		{
+			name:  "empty",
+			items: nil,
+			want:  []OrderItems{},
+		},
+		{
+			name:  "distinct books keep order",
+			items: []OrderItems{{BookID: 2, Quantity: 1}, {BookID: 1, Quantity: 3}},
+			want:  []OrderItems{{BookID: 2, Quantity: 1}, {BookID: 1, Quantity: 3}},
+		},
+		{
+			name: "same book merged into first position",
+			items: []OrderItems{
+				{BookID: 1, Quantity: 1, Price: 30},
+				{BookID: 2, Quantity: 2},
+				{BookID: 1, Quantity: 4, Price: 30},
+			},
+			want: []OrderItems{{BookID: 1, Quantity: 5, Price: 30}, {BookID: 2, Quantity: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeOrderItems(tt.items)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeOrderItems() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeOrderItemsDoesNotModifyInput(t *testing.T) {
+	items := []OrderItems{{BookID: 1, Quantity: 1}, {BookID: 1, Quantity: 2}}
+	mergeOrderItems(items)
+	if items[0].Quantity != 1 || items[1].Quantity != 2 {
+		t.Errorf("input modified: %+v", items)
+	}
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// permissionCacheTTL 用户权限集合在 Redis 中的缓存时间
+	permissionCacheTTL = 10 * time.Minute
+	// permWildcard 超级管理员在权限缓存中的标记
+	permWildcard = "*"
+	// permNone 没有任何权限时写入的占位成员（Redis 不能保存空集合）
+	permNone = "-"
+)
+
+// defaultPermissions 内置权限点
+var defaultPermissions = []model.Permission{
+	{Code: model.PermDashboardView, Name: "查看数据看板"},
+	{Code: model.PermBookView, Name: "查看图书"},
+	{Code: model.PermBookEdit, Name: "编辑图书", Description: "新增、修改（含价格）、删除、上下架图书"},
+	{Code: model.PermCategoryView, Name: "查看分类"},
+	{Code: model.PermCategoryEdit, Name: "编辑分类"},
+	{Code: model.PermUserView, Name: "查看用户"},
+	{Code: model.PermUserEdit, Name: "编辑用户", Description: "创建用户、修改资料、禁用账号"},
+	{Code: model.PermOrderView, Name: "查看订单"},
+	{Code: model.PermOrderCancel, Name: "取消订单"},
+	{Code: model.PermOrderFulfil, Name: "订单履约", Description: "打包、发货、确认送达"},
+	{Code: model.PermRefundManage, Name: "处理退款", Description: "审核退款申请并原路退款"},
+	{Code: model.PermSeckillManage, Name: "管理秒杀活动", Description: "创建、修改、停用秒杀活动"},
+	{Code: model.PermRoleManage, Name: "管理角色", Description: "维护角色权限并给用户分配角色"},
+}
+
+// defaultRoles 内置角色及其初始权限，只在角色第一次创建时写入，之后以后台修改为准
+var defaultRoles = []struct {
+	Role        model.Role
+	Permissions []string
+}{
+	{
+		Role: model.Role{Code: model.RoleSuperAdmin, Name: "超级管理员", Description: "拥有所有权限", IsSystem: true},
+	},
+	{
+		Role:        model.Role{Code: model.RoleCatalogEditor, Name: "商品编辑", Description: "维护图书、分类和秒杀活动", IsSystem: true},
+		Permissions: []string{model.PermBookView, model.PermBookEdit, model.PermCategoryView, model.PermCategoryEdit, model.PermSeckillManage},
+	},
+	{
+		Role:        model.Role{Code: model.RoleCustomerSupport, Name: "客服", Description: "查看和取消订单，不能修改商品", IsSystem: true},
+		Permissions: []string{model.PermOrderView, model.PermOrderCancel, model.PermOrderFulfil, model.PermUserView, model.PermBookView},
+	},
+	{
+		Role:        model.Role{Code: model.RoleFinance, Name: "财务", Description: "查看营收数据和订单", IsSystem: true},
+		Permissions: []string{model.PermDashboardView, model.PermOrderView, model.PermRefundManage},
+	},
+}
+
+type RBACService struct {
+	RoleDB *repository.RoleDAO
+}
+
+func NewRBACService() *RBACService {
+	return &RBACService{
+		RoleDB: repository.NewRoleDAO(),
+	}
+}
+
+// RoleRequest 新增/编辑角色的请求参数
+type RoleRequest struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// SeedDefaults 初始化内置权限和角色；把老管理员(IsAdmin)设为超级管理员的迁移只在第一次启动时执行
+func (s *RBACService) SeedDefaults() error {
+	for i := range defaultPermissions {
+		permission := defaultPermissions[i]
+		if err := s.RoleDB.EnsurePermission(&permission); err != nil {
+			return err
+		}
+	}
+	for _, def := range defaultRoles {
+		if _, err := s.RoleDB.GetRoleByCode(def.Role.Code); err == nil {
+			continue
+		}
+		role := def.Role
+		permissions, err := s.RoleDB.GetPermissionsByCodes(def.Permissions)
+		if err != nil {
+			return err
+		}
+		role.Permissions = permissions
+		if err := s.RoleDB.CreateRole(&role); err != nil {
+			return err
+		}
+	}
+
+	return s.migrateLegacyAdmins()
+}
+
+// migrateLegacyAdmins 从 IsAdmin 迁移到角色体系时，把当时还没有角色的管理员设为超级管理员。
+// 作为数据迁移只执行一次，之后新设置的管理员需要在后台分配角色，不会被自动提升
+func (s *RBACService) migrateLegacyAdmins() error {
+	_, err := repository.NewMigrationDAO().RunOnce("rbac_legacy_admins_to_super_admin", func(tx *gorm.DB) error {
+		roleDB := s.RoleDB.WithTx(tx)
+		superAdmin, err := roleDB.GetRoleByCode(model.RoleSuperAdmin)
+		if err != nil {
+			return err
+		}
+		userIDs, err := roleDB.GetAdminUserIDsWithoutRoles()
+		if err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			if err := roleDB.SetUserRoles(userID, []model.Role{*superAdmin}); err != nil {
+				return err
+			}
+		}
+		global.Logger.Info("已将老管理员迁移为超级管理员", zap.Int("count", len(userIDs)))
+		return nil
+	})
+	return err
+}
+
+// HasPermission 判断用户是否拥有某个权限，权限集合缓存在 Redis 的 user_perms:<id> 中
+func (s *RBACService) HasPermission(userID int64, code string) (bool, error) {
+	ctx := context.Background()
+	key := permissionCacheKey(userID)
+
+	exists, err := global.RedisClient.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if exists == 0 {
+		if err := s.loadPermissions(userID); err != nil {
+			return false, err
+		}
+	}
+	results, err := global.RedisClient.SMIsMember(ctx, key, permWildcard, code).Result()
+	if err != nil {
+		return false, err
+	}
+	return results[0] || results[1], nil
+}
+
+// GetUserPermissions 获取用户的权限编码列表，超级管理员返回 ["*"]
+func (s *RBACService) GetUserPermissions(userID int64) ([]string, error) {
+	roleCodes, permCodes, err := s.RoleDB.GetUserPermissionCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if hasRole(roleCodes, model.RoleSuperAdmin) {
+		return []string{permWildcard}, nil
+	}
+	return permCodes, nil
+}
+
+func (s *RBACService) loadPermissions(userID int64) error {
+	codes, err := s.GetUserPermissions(userID)
+	if err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		codes = []string{permNone}
+	}
+	members := make([]interface{}, len(codes))
+	for i, code := range codes {
+		members[i] = code
+	}
+	ctx := context.Background()
+	key := permissionCacheKey(userID)
+	pipe := global.RedisClient.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.SAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, permissionCacheTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RBACService) ListPermissions() ([]*model.Permission, error) {
+	return s.RoleDB.GetAllPermissions()
+}
+
+func (s *RBACService) ListRoles() ([]*model.Role, error) {
+	return s.RoleDB.GetAllRoles()
+}
+
+func (s *RBACService) CreateRole(req *RoleRequest) (*model.Role, error) {
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		return nil, errors.New("角色编码和名称不能为空")
+	}
+	if _, err := s.RoleDB.GetRoleByCode(req.Code); err == nil {
+		return nil, errors.New("角色编码已存在")
+	}
+	permissions, err := s.resolvePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	role := &model.Role{
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := s.RoleDB.CreateRole(role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole 修改角色名称和权限，角色编码不可修改
+func (s *RBACService) UpdateRole(id int64, req *RoleRequest) (*model.Role, error) {
+	role, err := s.RoleDB.GetRoleByID(id)
+	if err != nil {
+		return nil, errors.New("角色不存在")
+	}
+	if role.Code == model.RoleSuperAdmin {
+		return nil, errors.New("超级管理员角色不允许修改")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, errors.New("角色名称不能为空")
+	}
+	permissions, err := s.resolvePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	role.Name = req.Name
+	role.Description = req.Description
+	if err := s.RoleDB.UpdateRole(role, permissions); err != nil {
+		return nil, err
+	}
+	s.invalidateRoleMembers(role.ID)
+	return s.RoleDB.GetRoleByID(id)
+}
+
+func (s *RBACService) DeleteRole(id int64) error {
+	role, err := s.RoleDB.GetRoleByID(id)
+	if err != nil {
+		return errors.New("角色不存在")
+	}
+	if role.IsSystem {
+		return errors.New("内置角色不允许删除")
+	}
+	userIDs, err := s.RoleDB.GetUserIDsByRole(role.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.RoleDB.DeleteRole(role); err != nil {
+		return err
+	}
+	s.invalidateUsers(userIDs...)
+	return nil
+}
+
+// AssignUserRoles 用 roleIDs 整体替换用户的角色，权限缓存失效后下一个请求即按新角色鉴权。
+// 非超级管理员只能修改不高于自己的用户，并且只能授予自己拥有的角色
+func (s *RBACService) AssignUserRoles(operatorID, userID int64, roleIDs []int64) error {
+	unique := make(map[int64]struct{}, len(roleIDs))
+	for _, id := range roleIDs {
+		unique[id] = struct{}{}
+	}
+	roles := []model.Role{}
+	if len(unique) > 0 {
+		var err error
+		roles, err = s.RoleDB.GetRolesByIDs(roleIDs)
+		if err != nil {
+			return err
+		}
+		if len(roles) != len(unique) {
+			return errors.New("包含不存在的角色")
+		}
+	}
+	grant := make([]string, len(roles))
+	for i, role := range roles {
+		grant[i] = role.Code
+	}
+	if err := s.CheckOperator(operatorID, userID, true, grant...); err != nil {
+		return err
+	}
+	if err := s.RoleDB.SetUserRoles(userID, roles); err != nil {
+		return err
+	}
+	s.invalidateUsers(userID)
+	return nil
+}
+
+// CheckOperator 防止越权修改：目标用户拥有操作者没有的角色、或要授予操作者没有的角色时一律拒绝；
+// sensitive 为 true（分配角色、设置/取消管理员、修改其他管理员）时还要求操作者有角色管理权限。
+// 超级管理员不受限制。targetID 为 0 表示新建用户
+func (s *RBACService) CheckOperator(operatorID, targetID int64, sensitive bool, grantRoles ...string) error {
+	operatorRoles, _, err := s.RoleDB.GetUserPermissionCodes(operatorID)
+	if err != nil {
+		return err
+	}
+	if hasRole(operatorRoles, model.RoleSuperAdmin) {
+		return nil
+	}
+	if !coversRoles(operatorRoles, grantRoles) {
+		return ErrOperatorForbidden
+	}
+	if targetID != 0 {
+		targetRoles, _, err := s.RoleDB.GetUserPermissionCodes(targetID)
+		if err != nil {
+			return err
+		}
+		if !coversRoles(operatorRoles, targetRoles) {
+			return ErrOperatorForbidden
+		}
+	}
+	if sensitive {
+		ok, err := s.HasPermission(operatorID, model.PermRoleManage)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrOperatorForbidden
+		}
+	}
+	return nil
+}
+
+func (s *RBACService) resolvePermissions(codes []string) ([]model.Permission, error) {
+	unique := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		unique[code] = struct{}{}
+	}
+	if len(unique) == 0 {
+		return []model.Permission{}, nil
+	}
+	permissions, err := s.RoleDB.GetPermissionsByCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+	if len(permissions) != len(unique) {
+		return nil, errors.New("包含不存在的权限")
+	}
+	return permissions, nil
+}
+
+// invalidateRoleMembers 角色权限变化后，清除拥有该角色的用户的权限缓存
+func (s *RBACService) invalidateRoleMembers(roleID int64) {
+	userIDs, err := s.RoleDB.GetUserIDsByRole(roleID)
+	if err != nil {
+		global.Logger.Error("查询角色成员失败", zap.Error(err), zap.Int64("roleID", roleID))
+		return
+	}
+	s.invalidateUsers(userIDs...)
+}
+
+func (s *RBACService) invalidateUsers(userIDs ...int64) {
+	if len(userIDs) == 0 {
+		return
+	}
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = permissionCacheKey(id)
+	}
+	if err := global.RedisClient.Del(context.Background(), keys...).Err(); err != nil {
+		global.Logger.Error("清除权限缓存失败", zap.Error(err))
+	}
+}
+
+func hasRole(roleCodes []string, code string) bool {
+	for _, c := range roleCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// coversRoles held 是否包含 codes 中的全部角色，超级管理员包含一切
+func coversRoles(held, codes []string) bool {
+	if hasRole(held, model.RoleSuperAdmin) {
+		return true
+	}
+	for _, code := range codes {
+		if !hasRole(held, code) {
+			return false
+		}
+	}
+	return true
+}
+
+func permissionCacheKey(userID int64) string {
+	return fmt.Sprintf("user_perms:%d", userID)
+}
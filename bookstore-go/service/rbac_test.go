@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bookstore-manager/model"
+	"bookstore-manager/utils/testenv"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCoversRoles(t *testing.T) {
+	tests := []struct {
+		name  string
+		held  []string
+		codes []string
+		want  bool
+	}{
+		{"nothing requested", []string{model.RoleFinance}, nil, true},
+		{"subset", []string{model.RoleFinance, model.RoleCatalogEditor}, []string{model.RoleCatalogEditor}, true},
+		{"missing role", []string{model.RoleFinance}, []string{model.RoleCatalogEditor}, false},
+		{"non-super cannot cover super_admin", []string{model.RoleFinance, "role_admin"}, []string{model.RoleSuperAdmin}, false},
+		{"super_admin covers everything", []string{model.RoleSuperAdmin}, []string{model.RoleSuperAdmin, "role_admin"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coversRoles(tt.held, tt.codes); got != tt.want {
+				t.Errorf("coversRoles(%v, %v) = %v, want %v", tt.held, tt.codes, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAssignUserRolesOperatorLimits 只有 role:manage 权限的管理员不能给自己授予超级管理员，
+// 也不能移除超级管理员的角色
+func TestAssignUserRolesOperatorLimits(t *testing.T) {
+	db := testenv.MySQL(t, &model.User{}, &model.Permission{}, &model.Role{}, &model.DataMigration{})
+	testenv.Redis(t)
+	rbac := NewRBACService()
+	if err := rbac.SeedDefaults(); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+	superAdmin, err := rbac.RoleDB.GetRoleByCode(model.RoleSuperAdmin)
+	if err != nil {
+		t.Fatalf("GetRoleByCode: %v", err)
+	}
+
+	suffix := time.Now().UnixNano()
+	roleAdmin, err := rbac.CreateRole(&RoleRequest{
+		Code:        fmt.Sprintf("test_role_admin_%d", suffix),
+		Name:        "角色管理员",
+		Permissions: []string{model.PermRoleManage, model.PermUserEdit},
+	})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	t.Cleanup(func() { rbac.RoleDB.DeleteRole(roleAdmin) })
+
+	newUser := func(name string) *model.User {
+		user := &model.User{
+			Username: fmt.Sprintf("%s_%d", name, suffix),
+			Email:    fmt.Sprintf("%s_%d@example.com", name, suffix),
+			IsAdmin:  true,
+			Status:   model.UserStatusActive,
+		}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("创建用户失败: %v", err)
+		}
+		t.Cleanup(func() {
+			db.Exec("DELETE FROM user_roles WHERE user_id = ?", user.ID)
+			db.Unscoped().Delete(user)
+			rbac.invalidateUsers(user.ID)
+		})
+		return user
+	}
+	operator := newUser("operator")
+	root := newUser("root")
+	if err := rbac.RoleDB.SetUserRoles(operator.ID, []model.Role{*roleAdmin}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+	if err := rbac.RoleDB.SetUserRoles(root.ID, []model.Role{*superAdmin}); err != nil {
+		t.Fatalf("SetUserRoles: %v", err)
+	}
+
+	err = rbac.AssignUserRoles(operator.ID, operator.ID, []int64{roleAdmin.ID, superAdmin.ID})
+	if !errors.Is(err, ErrOperatorForbidden) {
+		t.Errorf("给自己授予超级管理员: err = %v, want ErrOperatorForbidden", err)
+	}
+	err = rbac.AssignUserRoles(operator.ID, root.ID, nil)
+	if !errors.Is(err, ErrOperatorForbidden) {
+		t.Errorf("移除超级管理员的角色: err = %v, want ErrOperatorForbidden", err)
+	}
+	roleCodes, _, err := rbac.RoleDB.GetUserPermissionCodes(operator.ID)
+	if err != nil {
+		t.Fatalf("GetUserPermissionCodes: %v", err)
+	}
+	if hasRole(roleCodes, model.RoleSuperAdmin) {
+		t.Errorf("操作者获得了超级管理员角色: %v", roleCodes)
+	}
+
+	// 授予自己拥有的角色是允许的
+	if err := rbac.AssignUserRoles(operator.ID, operator.ID, []int64{roleAdmin.ID}); err != nil {
+		t.Errorf("授予自己拥有的角色: %v", err)
+	}
+	// 超级管理员不受限制
+	if err := rbac.AssignUserRoles(root.ID, operator.ID, []int64{superAdmin.ID}); err != nil {
+		t.Errorf("超级管理员授予超级管理员: %v", err)
+	}
+}
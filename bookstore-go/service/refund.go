@@ -0,0 +1,336 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/payment"
+	"bookstore-manager/repository"
+	"bookstore-manager/utils/snowflake"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var errRefundNotFound = errors.New("退款单不存在")
+
+type RefundService struct {
+	RefundDB  *repository.RefundDAO
+	OrderDB   *repository.OrderDAO
+	PaymentDB *repository.PaymentDAO
+}
+
+func NewRefundService() *RefundService {
+	return &RefundService{
+		RefundDB:  repository.NewRefundDAO(),
+		OrderDB:   repository.NewOrderDAO(),
+		PaymentDB: repository.NewPaymentDAO(),
+	}
+}
+
+// RefundItemRequest 申请退款的订单项和数量
+type RefundItemRequest struct {
+	OrderItemID int64 `json:"order_item_id,string"`
+	Quantity    int   `json:"quantity"`
+}
+
+// RefundRequest 退款申请，Items 为空表示退还所有尚未退款的商品
+type RefundRequest struct {
+	Reason string              `json:"reason"`
+	Items  []RefundItemRequest `json:"items"`
+}
+
+// RequestRefund 用户申请退款/退货，订单进入退款中，等待后台审核
+func (s *RefundService) RequestRefund(userID, orderID int64, req *RefundRequest) (*model.Refund, error) {
+	order, err := s.OrderDB.GetOrderByID(orderID)
+	if err != nil || order.UserID != userID {
+		return nil, errors.New("订单不存在")
+	}
+	if !order.IsPaid || !model.CanTransitOrder(order.Status, model.OrderStatusRefunding) {
+		return nil, errors.New("当前订单状态不能申请退款")
+	}
+
+	items, err := buildRefundItems(order, req.Items)
+	if err != nil {
+		return nil, err
+	}
+	refund := &model.Refund{
+		RefundNo:   fmt.Sprintf("RF%d", snowflake.GenID()),
+		OrderID:    order.ID,
+		UserID:     userID,
+		Reason:     req.Reason,
+		Status:     model.RefundStatusPending,
+		PrevStatus: order.Status,
+		Items:      items,
+	}
+	for _, item := range items {
+		refund.Amount += item.Amount
+	}
+
+	err = s.OrderDB.TransitionOrder(order, &repository.OrderTransition{
+		To:        model.OrderStatusRefunding,
+		ActorType: model.OrderActorUser,
+		ActorID:   userID,
+		Reason:    "申请退款 " + refund.RefundNo,
+	}, func(tx *gorm.DB) error {
+		return s.RefundDB.WithTx(tx).CreateRefund(refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// buildRefundItems 校验申请的数量不超过剩余可退数量，金额按成交单价计算
+func buildRefundItems(order *model.Order, requested []RefundItemRequest) ([]model.RefundItem, error) {
+	var items []model.RefundItem
+	if len(requested) == 0 {
+		for _, oi := range order.OrderItems {
+			if remaining := oi.Quantity - oi.RefundedQuantity; remaining > 0 {
+				items = append(items, model.RefundItem{
+					OrderItemID: oi.ID,
+					BookID:      oi.BookID,
+					Quantity:    remaining,
+					Amount:      oi.Price * remaining,
+				})
+			}
+		}
+	} else {
+		seen := make(map[int64]bool, len(requested))
+		for _, r := range requested {
+			if seen[r.OrderItemID] {
+				return nil, errors.New("订单项重复")
+			}
+			seen[r.OrderItemID] = true
+			var oi *model.OrderItem
+			for i := range order.OrderItems {
+				if order.OrderItems[i].ID == r.OrderItemID {
+					oi = &order.OrderItems[i]
+					break
+				}
+			}
+			if oi == nil {
+				return nil, errors.New("订单项不存在")
+			}
+			if r.Quantity <= 0 || r.Quantity > oi.Quantity-oi.RefundedQuantity {
+				return nil, fmt.Errorf("退款数量超过可退数量（剩余%d件）", oi.Quantity-oi.RefundedQuantity)
+			}
+			items = append(items, model.RefundItem{
+				OrderItemID: oi.ID,
+				BookID:      oi.BookID,
+				Quantity:    r.Quantity,
+				Amount:      oi.Price * r.Quantity,
+			})
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("没有可退款的商品")
+	}
+	return items, nil
+}
+
+// ListOrderRefunds 用户查看订单的退款进度
+func (s *RefundService) ListOrderRefunds(userID, orderID int64) ([]*model.Refund, error) {
+	order, err := s.OrderDB.GetOrderByID(orderID)
+	if err != nil || order.UserID != userID {
+		return nil, errors.New("订单不存在")
+	}
+	return s.RefundDB.ListOrderRefunds(orderID)
+}
+
+// ListRefunds 后台退款单列表
+func (s *RefundService) ListRefunds(status string, page, pageSize int) ([]*model.Refund, int64, error) {
+	return s.RefundDB.ListRefunds(status, page, pageSize)
+}
+
+// GetRefund 后台退款单详情
+func (s *RefundService) GetRefund(id int64) (*model.Refund, error) {
+	refund, err := s.RefundDB.GetRefundByID(id)
+	if err != nil {
+		return nil, errRefundNotFound
+	}
+	return refund, nil
+}
+
+// ApproveRefund 审核通过并通过支付渠道退款。渠道同步返回成功时立即完成退款，
+// 否则等待退款回调；渠道退款失败的退款单可以再次审核通过以重试
+func (s *RefundService) ApproveRefund(adminID, refundID int64, remark string) (*model.Refund, error) {
+	refund, err := s.RefundDB.GetRefundByID(refundID)
+	if err != nil {
+		return nil, errRefundNotFound
+	}
+	now := time.Now()
+	err = s.RefundDB.UpdateRefundStatus(refund.ID,
+		[]string{model.RefundStatusPending, model.RefundStatusFailed}, model.RefundStatusApproved,
+		map[string]interface{}{"admin_id": adminID, "admin_remark": remark, "reviewed_at": &now})
+	if err != nil {
+		return nil, err
+	}
+	refund.Status = model.RefundStatusApproved
+	refund.AdminID = adminID
+
+	pay, err := s.PaymentDB.GetPaidPayment(refund.OrderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 接入支付渠道之前支付的订单没有支付单，只能线下退款，这里直接完成
+		global.Logger.Warn("订单没有支付单，按线下退款处理", zap.String("refundNo", refund.RefundNo))
+		return s.finishRefund(refund, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := payment.Get(pay.Provider)
+	if !ok {
+		s.markRefundFailed(refund)
+		return nil, fmt.Errorf("支付渠道 %s 未启用，无法退款", pay.Provider)
+	}
+	result, err := provider.Refund(&payment.RefundRequest{
+		PaymentNo: pay.PaymentNo,
+		RefundNo:  refund.RefundNo,
+		Amount:    refund.Amount,
+		Reason:    refund.Reason,
+	})
+	if err != nil {
+		global.Logger.Error("渠道退款失败", zap.Error(err), zap.String("refundNo", refund.RefundNo))
+		s.markRefundFailed(refund)
+		return nil, errors.New("渠道退款失败，请稍后重试")
+	}
+	if result.Status == payment.StatusSucceeded {
+		return s.finishRefund(refund, pay.ID)
+	}
+	return s.RefundDB.GetRefundByID(refund.ID)
+}
+
+// RejectRefund 驳回退款申请，订单回到申请前的状态
+func (s *RefundService) RejectRefund(adminID, refundID int64, remark string) (*model.Refund, error) {
+	refund, err := s.RefundDB.GetRefundByID(refundID)
+	if err != nil {
+		return nil, errRefundNotFound
+	}
+	if refund.Status != model.RefundStatusPending && refund.Status != model.RefundStatusFailed {
+		return nil, errors.New("只有待审核的退款单可以驳回")
+	}
+	order, err := s.OrderDB.GetOrderByID(refund.OrderID)
+	if err != nil {
+		return nil, errors.New("订单不存在")
+	}
+	if err := checkRefundExit(order, refund, refund.PrevStatus); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = s.OrderDB.TransitionOrder(order, &repository.OrderTransition{
+		To:        refund.PrevStatus,
+		ActorType: model.OrderActorAdmin,
+		ActorID:   adminID,
+		Reason:    "驳回退款 " + refund.RefundNo,
+	}, func(tx *gorm.DB) error {
+		return s.RefundDB.WithTx(tx).UpdateRefundStatus(refund.ID,
+			[]string{model.RefundStatusPending, model.RefundStatusFailed}, model.RefundStatusRejected,
+			map[string]interface{}{"admin_id": adminID, "admin_remark": remark, "reviewed_at": &now})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.RefundDB.GetRefundByID(refund.ID)
+}
+
+// HandleRefundResult 处理支付渠道的退款回调，重复回调不会重复入账
+func (s *RefundService) HandleRefundResult(refundNo, status string) error {
+	refund, err := s.RefundDB.GetRefundByNo(refundNo)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 订单关闭后自动退回的付款没有退款单
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if refund.Status != model.RefundStatusApproved {
+		return nil
+	}
+	switch status {
+	case payment.StatusSucceeded:
+		pay, err := s.PaymentDB.GetPaidPayment(refund.OrderID)
+		if err != nil {
+			return err
+		}
+		_, err = s.finishRefund(refund, pay.ID)
+		return err
+	case payment.StatusFailed:
+		s.markRefundFailed(refund)
+	}
+	return nil
+}
+
+// finishRefund 退款到账：归还库存、扣减销量和热销榜分数，全部商品都退完时订单变为已退款，
+// 否则回到申请前的状态
+func (s *RefundService) finishRefund(refund *model.Refund, paymentID int64) (*model.Refund, error) {
+	order, err := s.OrderDB.GetOrderByID(refund.OrderID)
+	if err != nil {
+		return nil, errors.New("订单不存在")
+	}
+	refunded := make(map[int64]int, len(refund.Items))
+	for _, item := range refund.Items {
+		refunded[item.OrderItemID] += item.Quantity
+	}
+	target := model.OrderStatusRefunded
+	for _, oi := range order.OrderItems {
+		if oi.RefundedQuantity+refunded[oi.ID] < oi.Quantity {
+			target = refund.PrevStatus
+			break
+		}
+	}
+	if err := checkRefundExit(order, refund, target); err != nil {
+		return nil, err
+	}
+
+	err = s.OrderDB.TransitionOrder(order, &repository.OrderTransition{
+		To:        target,
+		ActorType: model.OrderActorAdmin,
+		ActorID:   refund.AdminID,
+		Reason:    fmt.Sprintf("退款成功 %s，退款%d元", refund.RefundNo, refund.Amount),
+	}, func(tx *gorm.DB) error {
+		return s.RefundDB.WithTx(tx).ApplyRefund(refund, paymentID)
+	})
+	if errors.Is(err, repository.ErrRefundStateChanged) {
+		// 同步结果和回调同时到达，另一边已经处理
+		return s.RefundDB.GetRefundByID(refund.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	for _, item := range refund.Items {
+		adjustStockCache(item.BookID, item.Quantity)
+		err := global.RedisClient.ZIncrBy(ctx, "rank:hot_books", -float64(item.Quantity), strconv.FormatInt(item.BookID, 10)).Err()
+		if err != nil {
+			global.Logger.Error("更新热销榜失败", zap.Error(err), zap.Int64("bookID", item.BookID))
+		}
+	}
+	return s.RefundDB.GetRefundByID(refund.ID)
+}
+
+// checkRefundExit 退款中的订单只能由退款流程结束：退款完成变为已退款，驳回或部分退款回到申请前记录的状态。
+// 状态表允许退款中回到任意履约状态，这里保证回到的正是这张退款单申请前的状态
+func checkRefundExit(order *model.Order, refund *model.Refund, to int) error {
+	if order.Status != model.OrderStatusRefunding {
+		return &repository.OrderTransitionError{From: order.Status, To: to}
+	}
+	if to == model.OrderStatusRefunded {
+		return nil
+	}
+	if to != refund.PrevStatus || !model.CanTransitOrder(refund.PrevStatus, model.OrderStatusRefunding) {
+		return &repository.OrderTransitionError{From: order.Status, To: to}
+	}
+	return nil
+}
+
+func (s *RefundService) markRefundFailed(refund *model.Refund) {
+	err := s.RefundDB.UpdateRefundStatus(refund.ID, []string{model.RefundStatusApproved}, model.RefundStatusFailed, nil)
+	if err != nil && !errors.Is(err, repository.ErrRefundStateChanged) {
+		global.Logger.Error("更新退款单状态失败", zap.Error(err), zap.String("refundNo", refund.RefundNo))
+	}
+}
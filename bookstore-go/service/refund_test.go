@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"bookstore-manager/utils/testenv"
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBuildRefundItems(t *testing.T) {
+	orderItem := func(id, bookID int64, quantity, refunded, price int) model.OrderItem {
+		item := model.OrderItem{BookID: bookID, Quantity: quantity, RefundedQuantity: refunded, Price: price}
+		item.ID = id
+		return item
+	}
+	order := &model.Order{OrderItems: []model.OrderItem{
+		orderItem(11, 101, 2, 0, 30),
+		orderItem(12, 102, 3, 1, 20),
+		orderItem(13, 103, 1, 1, 50), // 已全部退款
+	}}
+
+	tests := []struct {
+		name      string
+		requested []RefundItemRequest
+		want      []model.RefundItem
+		wantErr   bool
+	}{
+		{
+			name:      "empty request refunds all remaining",
+			requested: nil,
+			want: []model.RefundItem{
+				{OrderItemID: 11, BookID: 101, Quantity: 2, Amount: 60},
+				{OrderItemID: 12, BookID: 102, Quantity: 2, Amount: 40},
+			},
+		},
+		{
+			name:      "partial quantity",
+			requested: []RefundItemRequest{{OrderItemID: 12, Quantity: 1}},
+			want:      []model.RefundItem{{OrderItemID: 12, BookID: 102, Quantity: 1, Amount: 20}},
+		},
+		{
+			name:      "exceeds remaining quantity",
+			requested: []RefundItemRequest{{OrderItemID: 12, Quantity: 3}},
+			wantErr:   true,
+		},
+		{
+			name:      "fully refunded item",
+			requested: []RefundItemRequest{{OrderItemID: 13, Quantity: 1}},
+			wantErr:   true,
+		},
+		{
+			name:      "zero quantity",
+			requested: []RefundItemRequest{{OrderItemID: 11, Quantity: 0}},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown order item",
+			requested: []RefundItemRequest{{OrderItemID: 99, Quantity: 1}},
+			wantErr:   true,
+		},
+		{
+			name: "duplicate order item",
+			requested: []RefundItemRequest{
+				{OrderItemID: 11, Quantity: 1},
+				{OrderItemID: 11, Quantity: 1},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildRefundItems(order, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildRefundItems() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildRefundItems() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("nothing left to refund", func(t *testing.T) {
+		done := &model.Order{OrderItems: []model.OrderItem{orderItem(13, 103, 1, 1, 50)}}
+		if _, err := buildRefundItems(done, nil); err == nil {
+			t.Error("buildRefundItems() error = nil, want error")
+		}
+	})
+}
+
+func TestCheckRefundExit(t *testing.T) {
+	refund := &model.Refund{PrevStatus: model.OrderStatusShipped}
+	tests := []struct {
+		name    string
+		status  int
+		to      int
+		prev    int
+		wantErr bool
+	}{
+		{"fully refunded", model.OrderStatusRefunding, model.OrderStatusRefunded, model.OrderStatusShipped, false},
+		{"back to prior status", model.OrderStatusRefunding, model.OrderStatusShipped, model.OrderStatusShipped, false},
+		{"other fulfilment status", model.OrderStatusRefunding, model.OrderStatusCompleted, model.OrderStatusShipped, true},
+		{"order not refunding", model.OrderStatusShipped, model.OrderStatusShipped, model.OrderStatusShipped, true},
+		{"invalid recorded status", model.OrderStatusRefunding, model.OrderStatusPending, model.OrderStatusPending, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refund.PrevStatus = tt.prev
+			err := checkRefundExit(&model.Order{Status: tt.status}, refund, tt.to)
+			var transitionErr *repository.OrderTransitionError
+			if got := errors.As(err, &transitionErr); got != tt.wantErr {
+				t.Errorf("checkRefundExit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRefundRestoresStockAndSale 部分退款后订单回到申请前的状态，全部退完后变为已退款，
+// 每次退款到账都归还库存、扣减销量
+func TestRefundRestoresStockAndSale(t *testing.T) {
+	db := testenv.MySQL(t, &model.Book{}, &model.Order{}, &model.OrderItem{}, &model.OrderStatusHistory{},
+		&model.Payment{}, &model.Refund{}, &model.RefundItem{})
+	redisClient := testenv.Redis(t)
+
+	book := &model.Book{Title: "退款测试", Price: 20, Stock: 10, Sale: 5, Status: 1}
+	if err := db.Create(book).Error; err != nil {
+		t.Fatalf("创建图书失败: %v", err)
+	}
+	userID := time.Now().UnixNano()
+	order := &model.Order{UserID: userID, OrderNo: "TEST" + strconv.FormatInt(userID, 10),
+		TotalAmount: 60, Status: model.OrderStatusDelivered, IsPaid: true}
+	if err := db.Create(order).Error; err != nil {
+		t.Fatalf("创建订单失败: %v", err)
+	}
+	item := &model.OrderItem{OrderID: order.ID, BookID: book.ID, Quantity: 3, ListPrice: 20, Price: 20, Subtotal: 60}
+	if err := db.Create(item).Error; err != nil {
+		t.Fatalf("创建订单项失败: %v", err)
+	}
+	t.Cleanup(func() {
+		var refundIDs []int64
+		db.Model(&model.Refund{}).Where("order_id = ?", order.ID).Pluck("id", &refundIDs)
+		db.Unscoped().Where("refund_id IN ?", append(refundIDs, 0)).Delete(&model.RefundItem{})
+		db.Unscoped().Where("order_id = ?", order.ID).Delete(&model.Refund{})
+		db.Unscoped().Where("order_id = ?", order.ID).Delete(&model.OrderStatusHistory{})
+		db.Unscoped().Delete(item)
+		db.Unscoped().Delete(order)
+		db.Unscoped().Delete(book)
+		redisClient.ZRem(context.Background(), "rank:hot_books", strconv.FormatInt(book.ID, 10))
+	})
+
+	s := NewRefundService()
+	refundAndCheck := func(quantity, wantStatus, wantStock, wantSale int) {
+		t.Helper()
+		refund, err := s.RequestRefund(userID, order.ID, &RefundRequest{
+			Items: []RefundItemRequest{{OrderItemID: item.ID, Quantity: quantity}},
+		})
+		if err != nil {
+			t.Fatalf("RequestRefund: %v", err)
+		}
+		refund, err = s.ApproveRefund(1, refund.ID, "")
+		if err != nil {
+			t.Fatalf("ApproveRefund: %v", err)
+		}
+		if refund.Status != model.RefundStatusRefunded {
+			t.Errorf("refund status = %s, want refunded", refund.Status)
+		}
+		var got model.Book
+		db.First(&got, book.ID)
+		if got.Stock != wantStock || got.Sale != wantSale {
+			t.Errorf("stock/sale = %d/%d, want %d/%d", got.Stock, got.Sale, wantStock, wantSale)
+		}
+		var status int
+		db.Model(&model.Order{}).Where("id = ?", order.ID).Pluck("status", &status)
+		if status != wantStatus {
+			t.Errorf("order status = %s, want %s", model.OrderStatusName(status), model.OrderStatusName(wantStatus))
+		}
+	}
+
+	refundAndCheck(1, model.OrderStatusDelivered, 11, 4)
+	refundAndCheck(2, model.OrderStatusRefunded, 13, 2)
+}
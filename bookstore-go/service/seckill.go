@@ -0,0 +1,491 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"bookstore-manager/utils/orderno"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// seckillKeyRetention 活动结束后 Redis 中的活动数据再保留一段时间，便于对账
+	seckillKeyRetention = 24 * time.Hour
+	// seckillResultTTL 抢购结果的保留时长，客户端在这段时间内轮询订单是否落库
+	seckillResultTTL = 24 * time.Hour
+)
+
+// 抢购结果状态
+const (
+	SeckillStatusPending = "pending" // 已抢到名额，订单排队落库中
+	SeckillStatusCreated = "created" // 订单已落库，可以去支付
+	SeckillStatusFailed  = "failed"  // 落库失败（如图书库存不足），名额已归还
+)
+
+// ErrSeckillBusy Redis 或消息队列不可用，控制器据此返回 503
+var ErrSeckillBusy = errors.New("系统繁忙，请稍后再试")
+
+var errSeckillOrderNotFound = errors.New("订单不存在")
+
+// seckillPurchaseScript 在一个脚本内完成活动时间、每人限购和库存的校验与扣减，
+// 成功返回图书ID（字符串，避免雪花ID在 Lua 的浮点数中丢失精度），失败返回负数错误码
+var seckillPurchaseScript = redis.NewScript(`
+local info = redis.call('HMGET', KEYS[1], 'book_id', 'start', 'end', 'limit')
+if not info[1] or redis.call('EXISTS', KEYS[2]) == 0 then
+	return -1
+end
+local now = tonumber(ARGV[2])
+if now < tonumber(info[2]) then
+	return -2
+end
+if now >= tonumber(info[3]) then
+	return -3
+end
+local qty = tonumber(ARGV[1])
+local bought = tonumber(redis.call('GET', KEYS[3]) or '0')
+if bought + qty > tonumber(info[4]) then
+	return -4
+end
+if redis.call('DECRBY', KEYS[2], qty) < 0 then
+	redis.call('INCRBY', KEYS[2], qty)
+	return -5
+end
+redis.call('INCRBY', KEYS[3], qty)
+redis.call('EXPIREAT', KEYS[3], tonumber(info[3]) + tonumber(ARGV[3]))
+return info[1]
+`)
+
+// seckillReleaseScript 归还活动库存和用户的限购名额，活动数据已过期时不再创建
+var seckillReleaseScript = redis.NewScript(`
+local qty = tonumber(ARGV[1])
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('INCRBY', KEYS[1], qty)
+end
+local bought = tonumber(redis.call('GET', KEYS[2]) or '0')
+if bought > 0 then
+	redis.call('DECRBY', KEYS[2], math.min(bought, qty))
+end
+return 1
+`)
+
+var seckillPurchaseErrors = map[int64]string{
+	-1: "活动不存在或已停用",
+	-2: "活动尚未开始",
+	-3: "活动已结束",
+	-4: "超过每人限购数量",
+	-5: "已经被抢光啦",
+}
+
+// 同一个活动的 key 使用相同的 hash tag，保证 Lua 脚本在集群模式下落在同一个节点
+func seckillInfoKey(campaignID int64) string {
+	return fmt.Sprintf("seckill:{%d}:info", campaignID)
+}
+
+func seckillStockKey(campaignID int64) string {
+	return fmt.Sprintf("seckill:{%d}:stock", campaignID)
+}
+
+func seckillBoughtKey(campaignID, userID int64) string {
+	return fmt.Sprintf("seckill:{%d}:user:%d", campaignID, userID)
+}
+
+func seckillOrderKey(orderNo string) string {
+	return "seckill:order:" + orderNo
+}
+
+type SeckillService struct {
+	CampaignDB   *repository.SeckillDAO
+	BookDB       *repository.BookDAO
+	OrderDB      *repository.OrderDAO
+	OrderService *OrderService
+}
+
+func NewSeckillService() *SeckillService {
+	return &SeckillService{
+		CampaignDB:   repository.NewSeckillDAO(),
+		BookDB:       repository.NewBookDAO(),
+		OrderDB:      repository.NewOrderDAO(),
+		OrderService: NewOrderService(),
+	}
+}
+
+// SeckillCampaignRequest 后台新增/编辑秒杀活动的请求参数，时间使用 RFC3339 格式
+type SeckillCampaignRequest struct {
+	Name         string    `json:"name"`
+	BookID       int64     `json:"book_id,string"`
+	SalePrice    int       `json:"sale_price"`
+	Quantity     int       `json:"quantity"`
+	PerUserLimit int       `json:"per_user_limit"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+}
+
+// Validate 校验活动参数（不涉及数据库的部分），每人限购未填时默认为 1
+func (r *SeckillCampaignRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("活动名称不能为空")
+	}
+	if r.SalePrice <= 0 {
+		return errors.New("秒杀价必须大于0")
+	}
+	if r.Quantity <= 0 {
+		return errors.New("活动库存必须大于0")
+	}
+	if r.PerUserLimit < 0 {
+		return errors.New("每人限购数量不能为负数")
+	}
+	if r.PerUserLimit == 0 {
+		r.PerUserLimit = 1
+	}
+	if r.StartTime.IsZero() || r.EndTime.IsZero() {
+		return errors.New("请设置活动时间")
+	}
+	if !r.EndTime.After(r.StartTime) {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	if !r.EndTime.After(time.Now()) {
+		return errors.New("结束时间必须晚于当前时间")
+	}
+	return nil
+}
+
+// SeckillCampaignView 活动及 Redis 中的剩余库存
+type SeckillCampaignView struct {
+	*model.SeckillCampaign
+	Remaining int64 `json:"remaining"`
+}
+
+// SeckillResult 异步抢购的结果，OrderID 只在订单落库后返回
+type SeckillResult struct {
+	OrderNo string `json:"order_no"`
+	Status  string `json:"status"`
+	OrderID int64  `json:"order_id,string,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// checkCampaignBook 活动图书必须在售，秒杀价不高于原价，活动库存不超过图书库存
+func (s *SeckillService) checkCampaignBook(req *SeckillCampaignRequest) error {
+	book, err := s.BookDB.GetBookByIDAnyStatus(req.BookID)
+	if err != nil {
+		return errors.New("图书不存在")
+	}
+	if book.Status != 1 {
+		return errors.New("图书未上架")
+	}
+	if req.SalePrice > book.Price {
+		return errors.New("秒杀价不能高于原价")
+	}
+	if req.Quantity > book.Stock {
+		return errors.New("活动库存不能超过图书库存")
+	}
+	return nil
+}
+
+// CreateCampaign 创建秒杀活动并把活动数据写入 Redis
+func (s *SeckillService) CreateCampaign(req *SeckillCampaignRequest) (*model.SeckillCampaign, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkCampaignBook(req); err != nil {
+		return nil, err
+	}
+	campaign := &model.SeckillCampaign{
+		Name:         req.Name,
+		BookID:       req.BookID,
+		SalePrice:    req.SalePrice,
+		Quantity:     req.Quantity,
+		PerUserLimit: req.PerUserLimit,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		Status:       1,
+	}
+	if err := s.CampaignDB.CreateCampaign(campaign); err != nil {
+		return nil, err
+	}
+	if err := warmSeckillCampaign(campaign, true); err != nil {
+		return nil, err
+	}
+	return s.CampaignDB.GetCampaignByID(campaign.ID)
+}
+
+// UpdateCampaign 修改活动。活动开始后已经有人抢购，库存和限购不能再改，只能停用
+func (s *SeckillService) UpdateCampaign(id int64, req *SeckillCampaignRequest) (*model.SeckillCampaign, error) {
+	campaign, err := s.CampaignDB.GetCampaignByID(id)
+	if err != nil {
+		return nil, errors.New("活动不存在")
+	}
+	if !time.Now().Before(campaign.StartTime) {
+		return nil, errors.New("活动已开始，不能修改，只能停用")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkCampaignBook(req); err != nil {
+		return nil, err
+	}
+	err = s.CampaignDB.UpdateCampaign(id, map[string]interface{}{
+		"name":           req.Name,
+		"book_id":        req.BookID,
+		"sale_price":     req.SalePrice,
+		"quantity":       req.Quantity,
+		"per_user_limit": req.PerUserLimit,
+		"start_time":     req.StartTime,
+		"end_time":       req.EndTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	campaign, err = s.CampaignDB.GetCampaignByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := warmSeckillCampaign(campaign, true); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// UpdateCampaignStatus 启用或停用活动。停用只移除活动信息，已售出的库存和限购记录保留，重新启用后继续生效
+func (s *SeckillService) UpdateCampaignStatus(id int64, status int) error {
+	if status != 0 && status != 1 {
+		return errors.New("状态只能为0(停用)或1(启用)")
+	}
+	if err := s.CampaignDB.UpdateCampaign(id, map[string]interface{}{"status": status}); err != nil {
+		return err
+	}
+	campaign, err := s.CampaignDB.GetCampaignByID(id)
+	if err != nil {
+		return errors.New("活动不存在")
+	}
+	return warmSeckillCampaign(campaign, false)
+}
+
+// ListCampaigns 后台活动列表
+func (s *SeckillService) ListCampaigns(status *int, page, pageSize int) ([]*model.SeckillCampaign, int64, error) {
+	return s.CampaignDB.ListCampaigns(status, page, pageSize)
+}
+
+// GetCampaign 活动详情，附带剩余库存
+func (s *SeckillService) GetCampaign(id int64) (*SeckillCampaignView, error) {
+	campaign, err := s.CampaignDB.GetCampaignByID(id)
+	if err != nil {
+		return nil, errors.New("活动不存在")
+	}
+	views := withRemaining([]*model.SeckillCampaign{campaign})
+	return views[0], nil
+}
+
+// ListOpenCampaigns 前台展示进行中和即将开始的活动
+func (s *SeckillService) ListOpenCampaigns() ([]*SeckillCampaignView, error) {
+	campaigns, err := s.CampaignDB.ListOpenCampaigns(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return withRemaining(campaigns), nil
+}
+
+// WarmUpCampaigns 启动时把未结束的活动写入 Redis。库存只在不存在时写入，不会覆盖已经扣减过的值
+func (s *SeckillService) WarmUpCampaigns() {
+	campaigns, err := s.CampaignDB.ListOpenCampaigns(time.Now())
+	if err != nil {
+		global.Logger.Error("预热秒杀活动失败", zap.Error(err))
+		return
+	}
+	for _, campaign := range campaigns {
+		if err := warmSeckillCampaign(campaign, false); err != nil {
+			global.Logger.Error("预热秒杀活动失败", zap.Int64("campaignID", campaign.ID), zap.Error(err))
+		}
+	}
+	global.Logger.Info("成功预热秒杀活动", zap.Int("count", len(campaigns)))
+}
+
+// Purchase 抢购：在 Redis 中原子地扣减活动库存和用户名额，然后把订单交给消息队列异步落库。
+// 返回的订单号用于轮询落库结果
+func (s *SeckillService) Purchase(userID, campaignID int64, quantity int) (string, error) {
+	if quantity == 0 {
+		quantity = 1
+	}
+	if quantity < 0 {
+		return "", errors.New("购买数量必须大于0")
+	}
+	ctx := context.Background()
+	result, err := seckillPurchaseScript.Run(ctx, global.RedisClient,
+		[]string{seckillInfoKey(campaignID), seckillStockKey(campaignID), seckillBoughtKey(campaignID, userID)},
+		quantity, time.Now().Unix(), int64(seckillKeyRetention.Seconds())).Result()
+	if err != nil {
+		global.Logger.Error("秒杀扣减库存失败", zap.Int64("campaignID", campaignID), zap.Error(err))
+		return "", ErrSeckillBusy
+	}
+	if code, ok := result.(int64); ok {
+		if message, known := seckillPurchaseErrors[code]; known {
+			return "", errors.New(message)
+		}
+		return "", ErrSeckillBusy
+	}
+	bookID, err := strconv.ParseInt(fmt.Sprint(result), 10, 64)
+	if err != nil {
+		releaseSeckillQuota(campaignID, userID, quantity)
+		return "", ErrSeckillBusy
+	}
+
+	orderNo := s.OrderService.GenerateOrderNo(orderno.ChannelSeckill)
+	orderKey := seckillOrderKey(orderNo)
+	pipe := global.RedisClient.TxPipeline()
+	pipe.HSet(ctx, orderKey, "user_id", userID, "status", SeckillStatusPending)
+	pipe.Expire(ctx, orderKey, seckillResultTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		releaseSeckillQuota(campaignID, userID, quantity)
+		return "", ErrSeckillBusy
+	}
+
+	msg := &OrderMessage{
+		UserID:     userID,
+		Items:      []OrderItems{{BookID: bookID, Quantity: quantity}},
+		OrderNo:    orderNo,
+		CampaignID: campaignID,
+		CreateTime: time.Now().Unix(),
+	}
+	if err := publishOrderMessage(msg); err != nil {
+		releaseOrderStock(msg)
+		global.RedisClient.Del(ctx, orderKey)
+		return "", ErrSeckillBusy
+	}
+	return orderNo, nil
+}
+
+// GetOrderResult 查询抢购订单是否已经落库。先查数据库，查不到再看 Redis 中的排队状态
+func (s *SeckillService) GetOrderResult(userID int64, orderNo string) (*SeckillResult, error) {
+	if err := orderno.Validate(orderNo); err != nil {
+		return nil, err
+	}
+	order, err := s.OrderDB.GetOrderByNo(orderNo)
+	if err == nil {
+		if order.UserID != userID {
+			return nil, errSeckillOrderNotFound
+		}
+		return &SeckillResult{OrderNo: orderNo, Status: SeckillStatusCreated, OrderID: order.ID}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	values, err := global.RedisClient.HGetAll(context.Background(), seckillOrderKey(orderNo)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if values["user_id"] != strconv.FormatInt(userID, 10) {
+		return nil, errSeckillOrderNotFound
+	}
+	return &SeckillResult{OrderNo: orderNo, Status: values["status"], Message: values["message"]}, nil
+}
+
+// priceSeckillItems 秒杀订单按活动价定价，不叠加图书折扣
+func (o *OrderService) priceSeckillItems(msg *OrderMessage) ([]*model.OrderItem, int, error) {
+	campaign, err := o.CampaignDB.GetCampaignByID(msg.CampaignID)
+	if err != nil {
+		return nil, 0, errors.New("活动不存在")
+	}
+	if campaign.Book == nil {
+		return nil, 0, errors.New("图书不存在")
+	}
+	var totalAmount int
+	orderItems := make([]*model.OrderItem, 0, len(msg.Items))
+	for _, item := range msg.Items {
+		if item.BookID != campaign.BookID || item.Quantity <= 0 {
+			return nil, 0, errors.New("订单项与活动不符")
+		}
+		subtotal := campaign.SalePrice * item.Quantity
+		totalAmount += subtotal
+		orderItems = append(orderItems, &model.OrderItem{
+			BookID:    item.BookID,
+			Quantity:  item.Quantity,
+			ListPrice: campaign.Book.Price,
+			Price:     campaign.SalePrice,
+			Subtotal:  subtotal,
+		})
+	}
+	return orderItems, totalAmount, nil
+}
+
+// warmSeckillCampaign 把活动写入 Redis。停用或已结束的活动只删除活动信息；
+// resetStock 为 true 时用活动总库存覆盖剩余库存，只能在活动开始前使用
+func warmSeckillCampaign(campaign *model.SeckillCampaign, resetStock bool) error {
+	ctx := context.Background()
+	infoKey := seckillInfoKey(campaign.ID)
+	if campaign.Status != 1 || !campaign.EndTime.After(time.Now()) {
+		return global.RedisClient.Del(ctx, infoKey).Err()
+	}
+	stockKey := seckillStockKey(campaign.ID)
+	expireAt := campaign.EndTime.Add(seckillKeyRetention)
+	pipe := global.RedisClient.TxPipeline()
+	pipe.HSet(ctx, infoKey,
+		"book_id", campaign.BookID,
+		"start", campaign.StartTime.Unix(),
+		"end", campaign.EndTime.Unix(),
+		"limit", campaign.PerUserLimit,
+	)
+	pipe.ExpireAt(ctx, infoKey, expireAt)
+	if resetStock {
+		pipe.Set(ctx, stockKey, campaign.Quantity, 0)
+	} else {
+		pipe.SetNX(ctx, stockKey, campaign.Quantity, 0)
+	}
+	pipe.ExpireAt(ctx, stockKey, expireAt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// withRemaining 从 Redis 读取各活动的剩余库存，读取失败时按 0 展示
+func withRemaining(campaigns []*model.SeckillCampaign) []*SeckillCampaignView {
+	views := make([]*SeckillCampaignView, 0, len(campaigns))
+	if len(campaigns) == 0 {
+		return views
+	}
+	keys := make([]string, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		keys = append(keys, seckillStockKey(campaign.ID))
+	}
+	values, err := global.RedisClient.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		global.Logger.Error("读取秒杀库存失败", zap.Error(err))
+	}
+	for i, campaign := range campaigns {
+		view := &SeckillCampaignView{SeckillCampaign: campaign}
+		if i < len(values) && values[i] != nil {
+			view.Remaining, _ = strconv.ParseInt(fmt.Sprint(values[i]), 10, 64)
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// releaseSeckillQuota 订单没能落库时归还活动库存和用户的限购名额
+func releaseSeckillQuota(campaignID, userID int64, quantity int) {
+	err := seckillReleaseScript.Run(context.Background(), global.RedisClient,
+		[]string{seckillStockKey(campaignID), seckillBoughtKey(campaignID, userID)}, quantity).Err()
+	if err != nil {
+		global.Logger.Error("归还秒杀名额失败", zap.Int64("campaignID", campaignID), zap.Int64("userID", userID), zap.Error(err))
+	}
+}
+
+// markSeckillFailed 记录抢购订单落库失败的原因，供客户端轮询
+func markSeckillFailed(orderNo, message string) {
+	ctx := context.Background()
+	key := seckillOrderKey(orderNo)
+	pipe := global.RedisClient.TxPipeline()
+	pipe.HSet(ctx, key, "status", SeckillStatusFailed, "message", message)
+	pipe.Expire(ctx, key, seckillResultTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		global.Logger.Error("记录秒杀结果失败", zap.String("orderNo", orderNo), zap.Error(err))
+	}
+}
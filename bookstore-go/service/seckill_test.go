@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bookstore-manager/utils/testenv"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSeckillPurchaseScript(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+	now := time.Now().Unix()
+	const userID = 42
+
+	type campaign struct {
+		start, end int64
+		limit      int
+		stock      int
+		bought     int
+		noInfo     bool
+	}
+	tests := []struct {
+		name       string
+		campaign   campaign
+		quantity   int
+		want       interface{}
+		wantStock  int
+		wantBought int
+	}{
+		{"success", campaign{now - 60, now + 60, 2, 10, 0, false}, 1, "9001", 9, 1},
+		{"buy up to limit", campaign{now - 60, now + 60, 2, 10, 1, false}, 1, "9001", 9, 2},
+		{"not started", campaign{now + 60, now + 120, 2, 10, 0, false}, 1, int64(-2), 10, 0},
+		{"ended", campaign{now - 120, now - 60, 2, 10, 0, false}, 1, int64(-3), 10, 0},
+		{"over per user limit", campaign{now - 60, now + 60, 2, 10, 2, false}, 1, int64(-4), 10, 2},
+		{"sold out", campaign{now - 60, now + 60, 5, 1, 0, false}, 2, int64(-5), 1, 0},
+		{"campaign missing", campaign{noInfo: true, stock: 10}, 1, int64(-1), 10, 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaignID := now*100 + int64(i)
+			keys := []string{seckillInfoKey(campaignID), seckillStockKey(campaignID), seckillBoughtKey(campaignID, userID)}
+			t.Cleanup(func() { client.Del(ctx, keys...) })
+			if !tt.campaign.noInfo {
+				client.HSet(ctx, keys[0], "book_id", "9001", "start", tt.campaign.start, "end", tt.campaign.end, "limit", tt.campaign.limit)
+			}
+			client.Set(ctx, keys[1], tt.campaign.stock, 0)
+			if tt.campaign.bought > 0 {
+				client.Set(ctx, keys[2], tt.campaign.bought, 0)
+			}
+
+			got, err := seckillPurchaseScript.Run(ctx, client, keys, tt.quantity, now, 3600).Result()
+			if err != nil {
+				t.Fatalf("script error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+			if stock, _ := client.Get(ctx, keys[1]).Int(); stock != tt.wantStock {
+				t.Errorf("stock = %d, want %d", stock, tt.wantStock)
+			}
+			if bought, _ := client.Get(ctx, keys[2]).Int(); bought != tt.wantBought {
+				t.Errorf("bought = %d, want %d", bought, tt.wantBought)
+			}
+		})
+	}
+}
+
+func TestReleaseSeckillQuota(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+	const userID = 42
+
+	tests := []struct {
+		name       string
+		stock      *int
+		bought     int
+		quantity   int
+		wantStock  *int
+		wantBought int
+	}{
+		{"restore stock and quota", intPtr(3), 2, 1, intPtr(4), 1},
+		{"quota never below zero", intPtr(3), 1, 2, intPtr(5), 0},
+		{"expired stock key is not recreated", nil, 1, 1, nil, 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaignID := time.Now().UnixNano()/1000 + int64(i)
+			keys := []string{seckillStockKey(campaignID), seckillBoughtKey(campaignID, userID)}
+			t.Cleanup(func() { client.Del(ctx, keys...) })
+			if tt.stock != nil {
+				client.Set(ctx, keys[0], *tt.stock, 0)
+			}
+			client.Set(ctx, keys[1], tt.bought, 0)
+
+			releaseSeckillQuota(campaignID, userID, tt.quantity)
+			stock, err := client.Get(ctx, keys[0]).Int()
+			switch {
+			case tt.wantStock == nil && err != redis.Nil:
+				t.Errorf("stock key exists (%d), want missing", stock)
+			case tt.wantStock != nil && stock != *tt.wantStock:
+				t.Errorf("stock = %d, want %d", stock, *tt.wantStock)
+			}
+			if bought, _ := client.Get(ctx, keys[1]).Int(); bought != tt.wantBought {
+				t.Errorf("bought = %d, want %d", bought, tt.wantBought)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
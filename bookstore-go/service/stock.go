@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// adjustStockScript 只在库存 key 存在时调整。下架图书的 key 已被删除，不能因为归还库存又被创建出来
+var adjustStockScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+return false
+`)
+
+// reserveStockScript 一次预占多本书的库存，全部充足才扣减，否则一本都不扣。
+// 返回 0 表示成功，否则返回第一项不足（或已下架、未预热）的序号
+var reserveStockScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	local stock = tonumber(redis.call('GET', key))
+	if not stock or stock < tonumber(ARGV[i]) then
+		return i
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call('DECRBY', key, ARGV[i])
+end
+return 0
+`)
+
+func stockKey(bookID int64) string {
+	return fmt.Sprintf("stock:%d", bookID)
+}
+
+// adjustStockCache 数据库库存变化后同步调整 Redis 中的 stock:<id>，delta 为负表示扣减。
+// 数据库是库存的权威来源，这里失败只记录日志
+func adjustStockCache(bookID int64, delta int) {
+	err := adjustStockScript.Run(context.Background(), global.RedisClient,
+		[]string{stockKey(bookID)}, delta).Err()
+	if err != nil && err != redis.Nil {
+		global.Logger.Error("同步Redis库存失败", zap.Error(err), zap.Int64("bookID", bookID), zap.Int("delta", delta))
+	}
+}
+
+// reserveStockCache 在 Redis 中原子地预占多本书的库存，任意一本不足时返回 false 且不扣减。
+// items 需先用 mergeOrderItems 合并，同一本书出现多次时脚本无法正确判断
+func reserveStockCache(items []OrderItems) (bool, error) {
+	keys := make([]string, 0, len(items))
+	args := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, stockKey(item.BookID))
+		args = append(args, item.Quantity)
+	}
+	index, err := reserveStockScript.Run(context.Background(), global.RedisClient, keys, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	if index > 0 {
+		global.Logger.Info("预占库存失败", zap.Int64("bookID", items[index-1].BookID))
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bookstore-manager/utils/testenv"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestReserveStockCache(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		stock        []int // -1 表示 key 不存在（已下架或未预热）
+		quantity     []int
+		wantReserved bool
+		wantStock    []int
+	}{
+		{"all available", []int{5, 3}, []int{2, 3}, true, []int{3, 0}},
+		{"one short reserves nothing", []int{5, 1}, []int{2, 3}, false, []int{5, 1}},
+		{"missing key reserves nothing", []int{5, -1}, []int{2, 1}, false, []int{5, -1}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := time.Now().UnixNano()/1000 + int64(i)*10
+			items := make([]OrderItems, len(tt.stock))
+			for j := range tt.stock {
+				items[j] = OrderItems{BookID: base + int64(j), Quantity: tt.quantity[j]}
+				key := stockKey(items[j].BookID)
+				t.Cleanup(func() { client.Del(ctx, key) })
+				if tt.stock[j] >= 0 {
+					client.Set(ctx, key, tt.stock[j], 0)
+				}
+			}
+
+			reserved, err := reserveStockCache(items)
+			if err != nil {
+				t.Fatalf("reserveStockCache: %v", err)
+			}
+			if reserved != tt.wantReserved {
+				t.Errorf("reserved = %v, want %v", reserved, tt.wantReserved)
+			}
+			for j, item := range items {
+				stock, err := client.Get(ctx, stockKey(item.BookID)).Int()
+				switch {
+				case tt.wantStock[j] < 0 && err != redis.Nil:
+					t.Errorf("book %d: stock key exists (%d), want missing", j, stock)
+				case tt.wantStock[j] >= 0 && stock != tt.wantStock[j]:
+					t.Errorf("book %d: stock = %d, want %d", j, stock, tt.wantStock[j])
+				}
+			}
+		})
+	}
+}
+
+func TestAdjustStockCacheDoesNotRecreateKey(t *testing.T) {
+	client := testenv.Redis(t)
+	ctx := context.Background()
+	bookID := time.Now().UnixNano() / 1000
+	key := stockKey(bookID)
+	t.Cleanup(func() { client.Del(ctx, key) })
+
+	adjustStockCache(bookID, 2)
+	if client.Exists(ctx, key).Val() != 0 {
+		t.Fatal("adjustStockCache created a missing stock key")
+	}
+	client.Set(ctx, key, 3, 0)
+	adjustStockCache(bookID, -1)
+	if stock, _ := client.Get(ctx, key).Int(); stock != 2 {
+		t.Errorf("stock = %d, want 2", stock)
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/jwt"
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	totpIssuer = "Bookstore"
+	// twoFactorSetupTTL 生成密钥后需要在这段时间内输入验证码完成绑定
+	twoFactorSetupTTL = 10 * time.Minute
+	// twoFactorChallengeTTL 密码验证通过后输入两步验证码的时限
+	twoFactorChallengeTTL = 5 * time.Minute
+	// twoFactorMaxAttempts 一次登录挑战最多允许输错的次数
+	twoFactorMaxAttempts = 5
+	recoveryCodeCount    = 10
+	// recoveryCodeAlphabet 去掉了容易混淆的 0/o、1/l/i
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var errTwoFactorCode = errors.New("验证码错误")
+
+type TwoFactorService struct {
+	UserDB *repository.UserDAO
+}
+
+func NewTwoFactorService() *TwoFactorService {
+	return &TwoFactorService{
+		UserDB: repository.NewUserDAO(),
+	}
+}
+
+// TwoFactorSetup 绑定验证器所需的信息，QRCode 为 base64 编码的 PNG 图片
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	QRCode     string `json:"qr_code"`
+}
+
+// Setup 生成新的 TOTP 密钥，用户用验证器扫码后调用 Enable 确认才会生效
+func (s *TwoFactorService) Setup(userID int64) (*TwoFactorSetup, error) {
+	user, err := s.UserDB.GetUserByID(userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if user.TwoFactorEnabled {
+		return nil, errors.New("已开启两步验证，如需更换请先关闭")
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Username,
+	})
+	if err != nil {
+		return nil, errors.New("生成密钥失败")
+	}
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return nil, errors.New("生成二维码失败")
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, errors.New("生成二维码失败")
+	}
+
+	err = global.RedisClient.Set(context.Background(), twoFactorSetupKey(userID), key.Secret(), twoFactorSetupTTL).Err()
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorSetup{
+		Secret:     key.Secret(),
+		OTPAuthURL: key.URL(),
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// Enable 校验验证器上的动态码，通过后开启两步验证并返回恢复码（只展示这一次）
+func (s *TwoFactorService) Enable(userID int64, code string) ([]string, error) {
+	ctx := context.Background()
+	secret, err := global.RedisClient.Get(ctx, twoFactorSetupKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, errors.New("绑定已过期，请重新获取二维码")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !validateTOTP(userID, secret, code) {
+		return nil, errTwoFactorCode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, errors.New("生成恢复码失败")
+	}
+	if err := s.UserDB.UpdateTwoFactor(userID, true, secret, hashes); err != nil {
+		return nil, errors.New("开启两步验证失败")
+	}
+	global.RedisClient.Del(ctx, twoFactorSetupKey(userID))
+	return codes, nil
+}
+
+// Disable 关闭两步验证，需要同时提供密码和动态码（或恢复码）
+func (s *TwoFactorService) Disable(userID int64, password, code string) error {
+	user, err := s.UserDB.GetUserByID(userID)
+	if err != nil {
+		return errors.New("用户不存在")
+	}
+	if !user.TwoFactorEnabled {
+		return errors.New("未开启两步验证")
+	}
+	if ok, _ := checkPassword(password, user.Password); !ok {
+		return errors.New("密码错误")
+	}
+	ok, err := checkSecondFactor(s.UserDB, user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errTwoFactorCode
+	}
+	if err := s.UserDB.UpdateTwoFactor(userID, false, "", ""); err != nil {
+		return errors.New("关闭两步验证失败")
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部作废。只接受验证器上的动态码
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID int64, code string) ([]string, error) {
+	user, err := s.UserDB.GetUserByID(userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if !user.TwoFactorEnabled {
+		return nil, errors.New("未开启两步验证")
+	}
+	if !validateTOTP(userID, user.TwoFactorSecret, code) {
+		return nil, errTwoFactorCode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, errors.New("生成恢复码失败")
+	}
+	if err := s.UserDB.UpdateTwoFactor(userID, true, user.TwoFactorSecret, hashes); err != nil {
+		return nil, errors.New("生成恢复码失败")
+	}
+	return codes, nil
+}
+
+// checkSecondFactor 校验动态码或恢复码，恢复码使用后立即作废
+func checkSecondFactor(userDB *repository.UserDAO, user *model.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 6 {
+		return validateTOTP(user.ID, user.TwoFactorSecret, code), nil
+	}
+
+	target := hashRecoveryCode(code)
+	hashes := strings.Split(user.RecoveryCodes, ",")
+	for i, hash := range hashes {
+		if hash == "" || hash != target {
+			continue
+		}
+		remaining := append(append([]string{}, hashes[:i]...), hashes[i+1:]...)
+		ok, err := userDB.ReplaceRecoveryCodes(user.ID, user.RecoveryCodes, strings.Join(remaining, ","))
+		if err != nil {
+			return false, err
+		}
+		return ok, nil
+	}
+	return false, nil
+}
+
+// validateTOTP 校验动态码，允许前后各一个周期的时钟误差；
+// 同一个动态码在有效期内只能使用一次，防止被截获后重放
+func validateTOTP(userID int64, secret, code string) bool {
+	ok, err := totp.ValidateCustom(code, secret, time.Now(), totp.ValidateOpts{
+		Period:    30,
+		Skew:      1,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	if err != nil || !ok {
+		return false
+	}
+	key := fmt.Sprintf("2fa_used:%d:%s", userID, code)
+	fresh, err := global.RedisClient.SetNX(context.Background(), key, 1, 90*time.Second).Result()
+	if err != nil {
+		global.Logger.Error("记录已使用的动态码失败", zap.Error(err))
+		return false
+	}
+	return fresh
+}
+
+// generateRecoveryCodes 生成恢复码，返回明文（给用户）和逗号拼接的哈希（入库）
+func generateRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	buf := make([]byte, 10)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", err
+		}
+		chars := make([]byte, len(buf))
+		for j, b := range buf {
+			chars[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		code := string(chars[:5]) + "-" + string(chars[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+// hashRecoveryCode 忽略大小写、空格和连字符后再哈希
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func twoFactorSetupKey(userID int64) string {
+	return fmt.Sprintf("2fa_setup:%d", userID)
+}
+
+// createTwoFactorChallenge 密码验证通过后创建登录挑战，保存完成登录所需的信息
+func createTwoFactorChallenge(userID int64, role string, meta *jwt.SessionMeta) (string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+	if meta == nil {
+		meta = &jwt.SessionMeta{}
+	}
+
+	ctx := context.Background()
+	key := twoFactorChallengeKey(token)
+	pipe := global.RedisClient.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", userID,
+		"role", role,
+		"device", meta.DeviceName,
+		"ip", meta.IP,
+		"user_agent", meta.UserAgent,
+		"attempts", 0,
+	)
+	pipe.Expire(ctx, key, twoFactorChallengeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// loadTwoFactorChallenge 读取登录挑战并累加尝试次数，超过次数后挑战作废
+func loadTwoFactorChallenge(token string) (int64, string, *jwt.SessionMeta, error) {
+	ctx := context.Background()
+	key := twoFactorChallengeKey(token)
+	attempts, err := global.RedisClient.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	values, err := global.RedisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return 0, "", nil, err
+	}
+	userID, err := strconv.ParseInt(values["user_id"], 10, 64)
+	if err != nil {
+		// HIncrBy 会在key不存在时创建它，这里顺手清理
+		global.RedisClient.Del(ctx, key)
+		return 0, "", nil, errors.New("登录已过期，请重新登录")
+	}
+	if attempts > twoFactorMaxAttempts {
+		global.RedisClient.Del(ctx, key)
+		return 0, "", nil, errors.New("验证码错误次数过多，请重新登录")
+	}
+	meta := &jwt.SessionMeta{
+		DeviceName: values["device"],
+		IP:         values["ip"],
+		UserAgent:  values["user_agent"],
+	}
+	return userID, values["role"], meta, nil
+}
+
+func deleteTwoFactorChallenge(token string) {
+	global.RedisClient.Del(context.Background(), twoFactorChallengeKey(token))
+}
+
+func twoFactorChallengeKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "2fa_challenge:" + hex.EncodeToString(sum[:])
+}
@@ -2,12 +2,14 @@ package service
 
 //service处理具体的业务逻辑
 import (
+	"bookstore-manager/global"
 	"bookstore-manager/jwt"
 	"bookstore-manager/model"
 	"bookstore-manager/mq"
 	"bookstore-manager/repository"
-	"encoding/base64"
 	"errors"
+
+	"go.uber.org/zap"
 )
 
 type UserService struct {
@@ -22,10 +24,14 @@ func NewUserService() *UserService {
 }
 
 type LoginResponse struct {
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
-	ExpireIn     int64     `json:"expire_in"`
-	UserInfo     *UserInfo `json:"user_info"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpireIn     int64     `json:"expire_in,omitempty"`
+	UserInfo     *UserInfo `json:"user_info,omitempty"`
+
+	// 开启了两步验证时只返回挑战token，需要调用两步验证接口换取正式token
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
 }
 
 type UserInfo struct {
@@ -33,6 +39,7 @@ type UserInfo struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Phone    string `json:"phone"`
+	IsAdmin  bool   `json:"is_admin"`
 }
 
 func (u *UserService) UserRegister(username, password, phone, email string) error {
@@ -45,10 +52,13 @@ func (u *UserService) UserRegister(username, password, phone, email string) erro
 		return errors.New("用户已存在，请检查用户名、邮箱或手机号")
 	}
 
-	//2、密码加密 （base64编码）
-	encodePassword := u.encodePassword(password)
+	//2、密码加密 （bcrypt哈希）
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return errors.New("密码加密失败")
+	}
 
-	err = u.createUser(username, encodePassword, phone, email)
+	user, err := u.createUser(username, hashedPassword, phone, email)
 	if err != nil {
 		return err
 	}
@@ -56,21 +66,104 @@ func (u *UserService) UserRegister(username, password, phone, email string) erro
 		// 这里可以发送用户名或者用户ID
 		mq.SendMessage("user.registered", username)
 	}()
+	// 注册后发送邮箱验证邮件，失败时用户可以在个人中心重新发送
+	go NewAccountService().sendEmailVerification(user)
 	return nil
 }
 
-func (u *UserService) UserLogin(username, password string) (*LoginResponse, error) {
-	//查询用户是否存在
+func (u *UserService) UserLogin(username, password string, meta *jwt.SessionMeta) (*LoginResponse, error) {
+	return u.login(username, password, jwt.RoleUser, meta)
+}
+
+// AdminLogin 后台登录，只有 IsAdmin 的账号才能拿到带管理员角色的token
+func (u *UserService) AdminLogin(username, password string, meta *jwt.SessionMeta) (*LoginResponse, error) {
+	return u.login(username, password, jwt.RoleAdmin, meta)
+}
+
+// login 校验账号密码，每次登录成功都会创建一个新的会话，不影响其他设备上的登录
+func (u *UserService) login(username, password, role string, meta *jwt.SessionMeta) (*LoginResponse, error) {
+	ip := ""
+	if meta != nil {
+		ip = meta.IP
+	}
+	//失败次数过多的账号或IP先拒绝，不再校验密码
+	if err := checkLoginAllowed(username, ip); err != nil {
+		return nil, err
+	}
+	//查询用户是否存在，不存在时也做一次哈希比较，让两种失败的耗时一致
 	user, err := u.UserDB.GetUserByUsername(username)
 	if err != nil {
-		return nil, errors.New("用户不存在")
+		checkPassword(password, dummyPasswordHash())
+		recordLoginFailure(username, ip)
+		return nil, errInvalidCredentials
 	}
 	//若用户存在则验证密码是否正确
-	if !u.verifyPassword(password, user.Password) {
-		return nil, errors.New("密码错误")
+	ok, needsRehash := checkPassword(password, user.Password)
+	if !ok {
+		recordLoginFailure(username, ip)
+		return nil, errInvalidCredentials
+	}
+	if err := clearLoginFailures(username); err != nil {
+		global.Logger.Error("清除登录失败记录失败", zap.Error(err))
+	}
+	//被禁用的账号不允许登录
+	if user.Status == model.UserStatusDisabled {
+		return nil, errors.New("账号已被禁用")
+	}
+	if role == jwt.RoleAdmin && !user.IsAdmin {
+		return nil, errors.New("该账号没有后台权限")
+	}
+	//旧格式的密码在登录成功时升级为当前配置的bcrypt哈希
+	if needsRehash {
+		u.rehashPassword(user.ID, password)
+	}
+	//开启了两步验证的账号先返回挑战token，验证动态码后再签发正式token
+	if user.TwoFactorEnabled {
+		challenge, err := createTwoFactorChallenge(user.ID, role, meta)
+		if err != nil {
+			return nil, errors.New("登录失败，请重试")
+		}
+		return &LoginResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
 	}
+	return u.issueLoginTokens(user, role, meta)
+}
+
+// VerifyTwoFactorLogin 登录第二步：校验动态码或恢复码，通过后签发token
+func (u *UserService) VerifyTwoFactorLogin(challengeToken, code string) (*LoginResponse, error) {
+	userID, role, meta, err := loadTwoFactorChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := u.UserDB.GetUserByID(userID)
+	if err != nil || user.Status == model.UserStatusDisabled || !user.TwoFactorEnabled ||
+		(role == jwt.RoleAdmin && !user.IsAdmin) {
+		deleteTwoFactorChallenge(challengeToken)
+		return nil, errors.New("账号状态已变更，请重新登录")
+	}
+	//动态码错误同样计入登录失败次数，否则拿到密码后可以反复创建挑战来穷举动态码
+	if err := checkLoginAllowed(user.Username, meta.IP); err != nil {
+		deleteTwoFactorChallenge(challengeToken)
+		return nil, err
+	}
+	ok, err := checkSecondFactor(u.UserDB, user, code)
+	if err != nil {
+		return nil, errors.New("验证失败，请重试")
+	}
+	if !ok {
+		recordLoginFailure(user.Username, meta.IP)
+		return nil, errTwoFactorCode
+	}
+	deleteTwoFactorChallenge(challengeToken)
+	return u.issueLoginTokens(user, role, meta)
+}
+
+// issueLoginTokens 登录校验全部通过后创建会话并签发token
+func (u *UserService) issueLoginTokens(user *model.User, role string, meta *jwt.SessionMeta) (*LoginResponse, error) {
 	//JWT
-	token, err := jwt.GenerateTokenPair(uint(user.ID), user.Username)
+	token, err := jwt.GenerateTokenPair(uint(user.ID), user.Username, role, meta)
 	if err != nil {
 		return nil, errors.New("生成token失败")
 	}
@@ -83,29 +176,95 @@ func (u *UserService) UserLogin(username, password string) (*LoginResponse, erro
 			Username: user.Username,
 			Email:    user.Email,
 			Phone:    user.Phone,
+			IsAdmin:  user.IsAdmin,
 		},
 	}
 	return response, nil
 }
 
-// 验证密码
-func (u *UserService) verifyPassword(inputPassword, truePassword string) bool {
-	eInput := u.encodePassword(inputPassword)
-	return eInput == truePassword
+// RefreshToken 用刷新token换取新的token对，刷新token每次使用后都会轮换。
+// 账号在此期间被禁用或取消了管理员权限时，撤销会话并要求重新登录
+func (u *UserService) RefreshToken(refreshToken string, meta *jwt.SessionMeta) (*LoginResponse, error) {
+	token, claims, err := jwt.RefreshTokenPair(refreshToken, meta)
+	if err != nil {
+		if errors.Is(err, jwt.ErrRefreshTokenInvalid) || errors.Is(err, jwt.ErrRefreshTokenReused) {
+			return nil, err
+		}
+		global.Logger.Error("刷新token失败", zap.Error(err))
+		return nil, errors.New("刷新token失败")
+	}
+	user, err := u.UserDB.GetUserByID(int64(claims.UserID))
+	if err != nil || user.Status == model.UserStatusDisabled ||
+		(claims.Role == jwt.RoleAdmin && !user.IsAdmin) {
+		if err := jwt.RevokeToken(claims.UserID); err != nil {
+			global.Logger.Error("撤销用户token失败", zap.Error(err), zap.Uint("userID", claims.UserID))
+		}
+		return nil, errors.New("账号状态已变更，请重新登录")
+	}
+	return &LoginResponse{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpireIn:     token.ExpiresIn,
+		UserInfo: &UserInfo{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Phone:    user.Phone,
+			IsAdmin:  user.IsAdmin,
+		},
+	}, nil
+}
+
+// ListSessions 当前用户所有登录中的设备
+func (u *UserService) ListSessions(userID int64, currentSessionID string) ([]*jwt.Session, error) {
+	return jwt.ListSessions(uint(userID), currentSessionID)
 }
 
-func (u *UserService) encodePassword(password string) string {
-	return base64.StdEncoding.EncodeToString([]byte(password))
+// RevokeSession 退出指定设备上的登录
+func (u *UserService) RevokeSession(userID int64, sessionID string) error {
+	err := jwt.RevokeSession(uint(userID), sessionID)
+	if errors.Is(err, jwt.ErrSessionNotFound) {
+		return err
+	}
+	if err != nil {
+		global.Logger.Error("撤销会话失败", zap.Error(err), zap.Int64("userID", userID))
+		return errors.New("退出设备失败")
+	}
+	return nil
 }
 
-func (u *UserService) createUser(username, password, phone, email string) error {
+// RevokeOtherSessions 退出除当前设备以外的所有登录
+func (u *UserService) RevokeOtherSessions(userID int64, currentSessionID string) (int, error) {
+	count, err := jwt.RevokeOtherSessions(uint(userID), currentSessionID)
+	if err != nil {
+		global.Logger.Error("撤销其他会话失败", zap.Error(err), zap.Int64("userID", userID))
+		return 0, errors.New("退出其他设备失败")
+	}
+	return count, nil
+}
+
+// rehashPassword 重新哈希密码，失败不影响本次登录，下次登录会再次尝试
+func (u *UserService) rehashPassword(userID int64, password string) {
+	hashed, err := hashPassword(password)
+	if err == nil {
+		err = u.UserDB.UpdatePassword(userID, hashed)
+	}
+	if err != nil {
+		global.Logger.Error("升级密码哈希失败", zap.Error(err), zap.Int64("userID", userID))
+	}
+}
+
+func (u *UserService) createUser(username, password, phone, email string) (*model.User, error) {
 	user := &model.User{
 		Username: username,
 		Password: password,
 		Phone:    phone,
 		Email:    email,
 	}
-	return u.UserDB.CreateUser(user)
+	if err := u.UserDB.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
 func (u *UserService) GetUserByID(userID int64) (*model.User, error) {
@@ -122,6 +281,11 @@ func (u *UserService) UpdateUserInfo(user *model.User) error {
 		return errors.New("用户不存在")
 	}
 	existinguser.Phone = user.Phone
+	//修改邮箱后需要重新验证
+	if existinguser.Email != user.Email {
+		existinguser.EmailVerified = false
+		existinguser.EmailVerifiedAt = nil
+	}
 	existinguser.Email = user.Email
 	existinguser.Avatar = user.Avatar
 
@@ -140,13 +304,15 @@ func (u *UserService) ChangePassword(userID int64, oldPassword, newPassword stri
 		return errors.New("用户不存在")
 	}
 	// 2.验证旧密码
-	if !u.verifyPassword(oldPassword, user.Password) {
+	if ok, _ := checkPassword(oldPassword, user.Password); !ok {
 		return errors.New("原密码错误")
 	}
 	// 3.更新新密码
-	enPassword := u.encodePassword(newPassword)
-	user.Password = enPassword
-	err = u.UserDB.UpdateUser(user)
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return errors.New("修改失败")
+	}
+	err = u.UserDB.UpdatePassword(user.ID, hashedPassword)
 	if err != nil {
 		return errors.New("修改失败")
 	}
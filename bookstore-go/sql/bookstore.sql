@@ -12,6 +12,12 @@ CREATE TABLE users (
     phone VARCHAR(20),
     avatar VARCHAR(255),
     is_admin BOOLEAN DEFAULT FALSE,
+    status TINYINT DEFAULT 1 COMMENT '账号状态：0-禁用，1-正常',
+    email_verified BOOLEAN DEFAULT FALSE COMMENT '邮箱是否已验证',
+    email_verified_at DATETIME NULL DEFAULT NULL COMMENT '邮箱验证时间',
+    two_factor_enabled BOOLEAN DEFAULT FALSE COMMENT '是否开启两步验证',
+    two_factor_secret VARCHAR(64) COMMENT 'TOTP密钥',
+    recovery_codes TEXT COMMENT '未使用的恢复码哈希',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -75,12 +81,15 @@ CREATE TABLE orders (
     user_id INT NOT NULL,
     order_no VARCHAR(50) NOT NULL COMMENT '订单号',
     total_amount INT NOT NULL COMMENT '总金额（元）',
-    status TINYINT DEFAULT 0 COMMENT '订单状态：0-待支付，1-已支付，2-已取消',
+    status TINYINT DEFAULT 0 COMMENT '订单状态：0-待支付，1-已支付，2-已取消，3-已打包，4-已发货，5-已送达，6-已完成，7-退款中，8-已退款',
     is_paid BOOLEAN DEFAULT FALSE COMMENT '是否已支付',
     payment_time TIMESTAMP NULL DEFAULT NULL,
+    stock_reserved BOOLEAN DEFAULT FALSE COMMENT '下单时是否已预占库存',
+    campaign_id INT NOT NULL DEFAULT 0 COMMENT '秒杀活动ID，普通订单为0',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY uk_order_no (order_no),
+    INDEX idx_campaign_id (campaign_id),
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
@@ -90,14 +99,107 @@ CREATE TABLE order_items (
     order_id INT NOT NULL,
     book_id INT NOT NULL,
     quantity INT NOT NULL,
-    price INT NOT NULL COMMENT '单价（元）',
+    list_price INT NOT NULL DEFAULT 0 COMMENT '原价（元）',
+    discount INT NOT NULL DEFAULT 0 COMMENT '折扣（百分比，0表示无折扣）',
+    price INT NOT NULL COMMENT '折后单价（元）',
     subtotal INT NOT NULL COMMENT '小计（元）',
+    refunded_quantity INT NOT NULL DEFAULT 0 COMMENT '已退款数量',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
     FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
+-- 创建订单状态变更记录表
+CREATE TABLE order_status_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    from_status TINYINT NOT NULL,
+    to_status TINYINT NOT NULL,
+    actor_type VARCHAR(16) NOT NULL COMMENT '操作者类型：user、admin、system',
+    actor_id INT DEFAULT 0 COMMENT '操作者ID，系统操作为0',
+    reason VARCHAR(255) DEFAULT NULL COMMENT '变更原因',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_order_id (order_id),
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 创建支付单表
+CREATE TABLE payments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    payment_no VARCHAR(64) NOT NULL COMMENT '支付单号',
+    order_id INT NOT NULL,
+    user_id INT NOT NULL,
+    provider VARCHAR(32) NOT NULL COMMENT '支付渠道',
+    amount INT NOT NULL COMMENT '支付金额（元）',
+    refunded_amount INT NOT NULL DEFAULT 0 COMMENT '已退款金额（元）',
+    status VARCHAR(16) NOT NULL COMMENT '状态：pending、succeeded、failed、refunded',
+    trade_no VARCHAR(64) DEFAULT NULL COMMENT '渠道流水号',
+    pay_url VARCHAR(255) DEFAULT NULL COMMENT '收银台地址',
+    paid_at DATETIME NULL DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_payment_no (payment_no),
+    INDEX idx_order_id (order_id),
+    INDEX idx_status (status),
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 创建退款单表
+CREATE TABLE refunds (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    refund_no VARCHAR(64) NOT NULL COMMENT '退款单号',
+    order_id INT NOT NULL,
+    user_id INT NOT NULL,
+    amount INT NOT NULL COMMENT '退款金额（元）',
+    reason VARCHAR(255) DEFAULT NULL COMMENT '退款原因',
+    status VARCHAR(16) NOT NULL COMMENT '状态：pending、approved、rejected、refunded、failed',
+    prev_status TINYINT NOT NULL COMMENT '申请前的订单状态',
+    admin_id INT DEFAULT 0 COMMENT '审核人',
+    admin_remark VARCHAR(255) DEFAULT NULL COMMENT '审核备注',
+    reviewed_at DATETIME NULL DEFAULT NULL,
+    refunded_at DATETIME NULL DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_refund_no (refund_no),
+    INDEX idx_order_id (order_id),
+    INDEX idx_status (status),
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 创建退款明细表
+CREATE TABLE refund_items (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    refund_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    book_id INT NOT NULL,
+    quantity INT NOT NULL COMMENT '退款数量',
+    amount INT NOT NULL COMMENT '退款金额（元）',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_refund_id (refund_id),
+    FOREIGN KEY (refund_id) REFERENCES refunds(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 创建秒杀活动表
+CREATE TABLE seckill_campaigns (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL COMMENT '活动名称',
+    book_id INT NOT NULL,
+    sale_price INT NOT NULL COMMENT '秒杀价（元）',
+    quantity INT NOT NULL COMMENT '活动总库存',
+    per_user_limit INT NOT NULL DEFAULT 1 COMMENT '每个用户限购数量',
+    start_time DATETIME NOT NULL COMMENT '开始时间',
+    end_time DATETIME NOT NULL COMMENT '结束时间',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1-启用，0-停用',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_book_id (book_id),
+    INDEX idx_time (start_time, end_time),
+    FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
 -- 创建轮播图表
 CREATE TABLE carousel (
     id INT PRIMARY KEY AUTO_INCREMENT,
@@ -0,0 +1,106 @@
+package orderno
+
+import (
+	"bookstore-manager/utils/snowflake"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// 下单渠道编码
+const (
+	ChannelWeb     = "01" // 普通下单、购物车结算
+	ChannelAsync   = "02" // 异步下单
+	ChannelSeckill = "03" // 秒杀
+)
+
+// ErrInvalid 订单号格式或校验位不正确
+var ErrInvalid = errors.New("订单号格式不正确")
+
+const (
+	dateLayout = "20060102"
+	// 订单号 = 8位日期 + 2位渠道 + 19位雪花ID（左侧补0） + 1位校验位
+	idDigits = 19
+	length   = len(dateLayout) + 2 + idDigits + 1
+)
+
+var channels = map[string]bool{
+	ChannelWeb:     true,
+	ChannelAsync:   true,
+	ChannelSeckill: true,
+}
+
+// legacyPattern 旧版本的订单号（ORD + 纳秒时间戳），只用于查询历史订单
+var legacyPattern = regexp.MustCompile(`^ORD\d{1,19}$`)
+
+// Info 订单号中携带的信息
+type Info struct {
+	Date    time.Time
+	Channel string
+	ID      int64
+}
+
+// Generate 用雪花ID生成订单号，日期取自雪花ID中的时间，多实例之间只要机器ID不同就不会重复
+func Generate(channel string) string {
+	id, at := snowflake.GenIDWithTime()
+	payload := fmt.Sprintf("%s%s%0*d", at.Format(dateLayout), channel, idDigits, id)
+	return payload + string(checkDigit(payload))
+}
+
+// Parse 校验并解析新格式的订单号
+func Parse(no string) (*Info, error) {
+	if len(no) != length {
+		return nil, ErrInvalid
+	}
+	for i := 0; i < len(no); i++ {
+		if no[i] < '0' || no[i] > '9' {
+			return nil, ErrInvalid
+		}
+	}
+	payload := no[:length-1]
+	if checkDigit(payload) != no[length-1] {
+		return nil, ErrInvalid
+	}
+	date, err := time.ParseInLocation(dateLayout, no[:8], time.Local)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	channel := no[8:10]
+	if !channels[channel] {
+		return nil, ErrInvalid
+	}
+	id, err := strconv.ParseInt(no[10:length-1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return &Info{Date: date, Channel: channel, ID: id}, nil
+}
+
+// Validate 校验订单号，兼容旧格式
+func Validate(no string) error {
+	if legacyPattern.MatchString(no) {
+		return nil
+	}
+	_, err := Parse(no)
+	return err
+}
+
+// checkDigit Luhn 校验位，能发现单个数字错误和大多数相邻数字颠倒
+func checkDigit(payload string) byte {
+	sum := 0
+	double := true
+	for i := len(payload) - 1; i >= 0; i-- {
+		d := int(payload[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,134 @@
+package orderno
+
+import (
+	"bookstore-manager/utils/snowflake"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	if err := snowflake.Init("2023-12-01", 1); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestGenerateParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+	}{
+		{"web", ChannelWeb},
+		{"async", ChannelAsync},
+		{"seckill", ChannelSeckill},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			no := Generate(tt.channel)
+			if len(no) != length {
+				t.Fatalf("len(%q) = %d, want %d", no, len(no), length)
+			}
+			info, err := Parse(no)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", no, err)
+			}
+			if info.Channel != tt.channel {
+				t.Errorf("Channel = %q, want %q", info.Channel, tt.channel)
+			}
+			if info.ID <= 0 {
+				t.Errorf("ID = %d, want > 0", info.ID)
+			}
+			today := time.Now().Format(dateLayout)
+			if got := info.Date.Format(dateLayout); got != today {
+				t.Errorf("Date = %s, want %s", got, today)
+			}
+		})
+	}
+}
+
+func TestGenerateUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		no := Generate(ChannelWeb)
+		if seen[no] {
+			t.Fatalf("duplicate order number %s", no)
+		}
+		seen[no] = true
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := Generate(ChannelWeb)
+	// 改动最后一位数字之前的任意一位，校验位都应该发现
+	flipped := []byte(valid)
+	flipped[12] = '0' + (flipped[12]-'0'+1)%10
+	payload := "20260101" + "09" + "0000000000000000001"
+	badChannel := payload + string(checkDigit(payload))
+	payload = "20261301" + ChannelWeb + "0000000000000000001"
+	badDate := payload + string(checkDigit(payload))
+
+	tests := []struct {
+		name string
+		no   string
+	}{
+		{"empty", ""},
+		{"too short", valid[:length-1]},
+		{"too long", valid + "0"},
+		{"non digit", "A" + valid[1:]},
+		{"flipped digit", string(flipped)},
+		{"wrong check digit", valid[:length-1] + string('0'+(valid[length-1]-'0'+1)%10)},
+		{"unknown channel", badChannel},
+		{"invalid date", badDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.no); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) error = %v, want ErrInvalid", tt.no, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		no      string
+		wantErr bool
+	}{
+		{"new format", Generate(ChannelAsync), false},
+		{"legacy", "ORD1702345678901234567", false},
+		{"legacy too long", "ORD12345678901234567890", true},
+		{"legacy without digits", "ORD", true},
+		{"garbage", "hello", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.no); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q) error = %v, wantErr %v", tt.no, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    byte
+	}{
+		// Luhn 标准示例
+		{"7992739871", '3'},
+		{"0", '0'},
+		{"1", '8'},
+		{"000000000", '0'},
+		{"4111111111111111"[:15], '1'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			if got := checkDigit(tt.payload); got != tt.want {
+				t.Errorf("checkDigit(%q) = %c, want %c", tt.payload, got, tt.want)
+			}
+		})
+	}
+}
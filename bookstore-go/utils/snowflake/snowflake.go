@@ -29,3 +29,9 @@ func Init(startTime string, machineID int64) (err error) {
 func GenID() int64 {
 	return node.Generate().Int64()
 }
+
+// GenIDWithTime 生成 ID，并返回 ID 中记录的生成时间
+func GenIDWithTime() (int64, time.Time) {
+	id := node.Generate()
+	return id.Int64(), time.UnixMilli(id.Time())
+}
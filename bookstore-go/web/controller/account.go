@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"bookstore-manager/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountController struct {
+	AccountService *service.AccountService
+}
+
+func NewAccountController() *AccountController {
+	return &AccountController{
+		AccountService: service.NewAccountService(),
+	}
+}
+
+// ForgotPassword 申请重置密码，向注册邮箱发送重置链接
+func (a *AccountController) ForgotPassword(ctx *gin.Context) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := a.AccountService.RequestPasswordReset(req.Email); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "如果该邮箱已注册，重置密码的邮件将很快送达",
+	})
+}
+
+// ResetPassword 使用邮件中的token设置新密码
+func (a *AccountController) ResetPassword(ctx *gin.Context) {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	if err := a.AccountService.ResetPassword(req.Token, req.NewPassword); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "密码已重置，请重新登录",
+	})
+}
+
+// SendEmailVerification 给当前用户的邮箱发送验证链接
+func (a *AccountController) SendEmailVerification(ctx *gin.Context) {
+	if err := a.AccountService.SendEmailVerification(getUserID(ctx)); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "验证邮件已发送",
+	})
+}
+
+// VerifyEmail 使用邮件中的token完成邮箱验证
+func (a *AccountController) VerifyEmail(ctx *gin.Context) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	if err := a.AccountService.VerifyEmail(req.Token); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "邮箱验证成功",
+	})
+}
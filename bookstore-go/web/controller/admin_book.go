@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"bookstore-manager/repository"
+	"bookstore-manager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminBookController struct {
+	AdminBookService *service.AdminBookService
+}
+
+func NewAdminBookController() *AdminBookController {
+	return &AdminBookController{
+		AdminBookService: service.NewAdminBookService(),
+	}
+}
+
+// ListBooks 后台图书列表，支持 title/author/type/category_id/status 筛选
+func (a *AdminBookController) ListBooks(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filter := &repository.BookFilter{
+		Title:  ctx.Query("title"),
+		Author: ctx.Query("author"),
+		Type:   ctx.Query("type"),
+	}
+	if categoryID := ctx.Query("category_id"); categoryID != "" {
+		id, err := strconv.ParseInt(categoryID, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的分类ID",
+			})
+			return
+		}
+		filter.CategoryID = id
+	}
+	if status := ctx.Query("status"); status != "" {
+		s, err := strconv.Atoi(status)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的状态",
+			})
+			return
+		}
+		filter.Status = &s
+	}
+
+	books, total, err := a.AdminBookService.ListBooks(filter, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取图书列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取图书列表成功",
+		"data": gin.H{
+			"books":       books,
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// GetBook 后台图书详情（包含已下架图书）
+func (a *AdminBookController) GetBook(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的书籍ID",
+		})
+		return
+	}
+	book, err := a.AdminBookService.GetBook(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取书籍信息成功",
+		"data":    book,
+	})
+}
+
+// CreateBook 新增图书
+func (a *AdminBookController) CreateBook(ctx *gin.Context) {
+	var req service.AdminBookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	book, err := a.AdminBookService.CreateBook(&req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "新增图书失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "新增图书成功",
+		"data":    book,
+	})
+}
+
+// UpdateBook 编辑图书
+func (a *AdminBookController) UpdateBook(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的书籍ID",
+		})
+		return
+	}
+	var req service.AdminBookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	book, err := a.AdminBookService.UpdateBook(id, &req)
+	if errors.Is(err, repository.ErrStockConflict) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "更新图书失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新图书成功",
+		"data":    book,
+	})
+}
+
+// DeleteBook 删除图书
+func (a *AdminBookController) DeleteBook(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的书籍ID",
+		})
+		return
+	}
+	if err := a.AdminBookService.DeleteBook(id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "删除图书失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "删除图书成功",
+	})
+}
+
+// UpdateBookStatus 上架/下架图书，状态优先取查询参数 ?status=N，其次取 JSON 请求体
+func (a *AdminBookController) UpdateBookStatus(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的书籍ID",
+		})
+		return
+	}
+	var req struct {
+		Status *int `json:"status"`
+	}
+	if status := ctx.Query("status"); status != "" {
+		s, err := strconv.Atoi(status)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的状态",
+			})
+			return
+		}
+		req.Status = &s
+	} else if err := ctx.ShouldBindJSON(&req); err != nil {
+		req.Status = nil
+	}
+	if req.Status == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	book, err := a.AdminBookService.UpdateBookStatus(id, *req.Status)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "更新图书状态失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新图书状态成功",
+		"data":    book,
+	})
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"bookstore-manager/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminCategoryController struct {
+	AdminCategoryService *service.AdminCategoryService
+}
+
+func NewAdminCategoryController() *AdminCategoryController {
+	return &AdminCategoryController{
+		AdminCategoryService: service.NewAdminCategoryService(),
+	}
+}
+
+// ListCategories 后台分类列表（包含未启用的分类）
+func (a *AdminCategoryController) ListCategories(ctx *gin.Context) {
+	categories, err := a.AdminCategoryService.ListCategories()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取分类列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取分类列表成功",
+		"data":    categories,
+	})
+}
+
+// GetCategory 分类详情
+func (a *AdminCategoryController) GetCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的分类ID",
+		})
+		return
+	}
+	category, err := a.AdminCategoryService.GetCategory(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取分类成功",
+		"data":    category,
+	})
+}
+
+// CreateCategory 新增分类
+func (a *AdminCategoryController) CreateCategory(ctx *gin.Context) {
+	var req service.AdminCategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	category, err := a.AdminCategoryService.CreateCategory(&req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "新增分类失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "新增分类成功",
+		"data":    category,
+	})
+}
+
+// UpdateCategory 编辑分类
+func (a *AdminCategoryController) UpdateCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的分类ID",
+		})
+		return
+	}
+	var req service.AdminCategoryRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	category, err := a.AdminCategoryService.UpdateCategory(id, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "更新分类失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新分类成功",
+		"data":    category,
+	})
+}
+
+// DeleteCategory 删除分类，分类下还有图书时需要通过 reassign_to 指定迁移的目标分类
+func (a *AdminCategoryController) DeleteCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的分类ID",
+		})
+		return
+	}
+	var reassignTo int64
+	if target := ctx.Query("reassign_to"); target != "" {
+		reassignTo, err = strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的目标分类ID",
+			})
+			return
+		}
+	}
+	if err := a.AdminCategoryService.DeleteCategory(id, reassignTo); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "删除分类成功",
+	})
+}
+
+// ReorderCategories 批量调整分类排序
+func (a *AdminCategoryController) ReorderCategories(ctx *gin.Context) {
+	var req struct {
+		Items []service.CategorySortItem `json:"items"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := a.AdminCategoryService.ReorderCategories(req.Items); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "调整排序失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "调整排序成功",
+	})
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"bookstore-manager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminDashboardController struct {
+	DashboardService *service.DashboardService
+}
+
+func NewAdminDashboardController() *AdminDashboardController {
+	return &AdminDashboardController{
+		DashboardService: service.NewDashboardService(),
+	}
+}
+
+// GetStats 后台看板统计，可选参数 from/to (YYYY-MM-DD) 和 top (畅销榜数量)
+func (a *AdminDashboardController) GetStats(ctx *gin.Context) {
+	top, _ := strconv.Atoi(ctx.DefaultQuery("top", "10"))
+	if top < 1 || top > 50 {
+		top = 10
+	}
+	stats, err := a.DashboardService.GetStats(ctx.Query("from"), ctx.Query("to"), top)
+	var rangeErr *service.DashboardRangeError
+	if errors.As(err, &rangeErr) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": rangeErr.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取统计数据失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    stats,
+	})
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"bookstore-manager/service"
+	"bookstore-manager/utils/orderno"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminOrderController struct {
+	OrderService *service.OrderService
+}
+
+func NewAdminOrderController() *AdminOrderController {
+	return &AdminOrderController{
+		OrderService: service.NewOrderService(),
+	}
+}
+
+// ListOrders 后台订单列表，支持 order_no/user_id/status 筛选，status 可以是数字或英文名（如 shipped）
+func (a *AdminOrderController) ListOrders(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filter := &repository.OrderFilter{
+		OrderNo: ctx.Query("order_no"),
+	}
+	if filter.OrderNo != "" {
+		if err := orderno.Validate(filter.OrderNo); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	if userID := ctx.Query("user_id"); userID != "" {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的用户ID",
+			})
+			return
+		}
+		filter.UserID = id
+	}
+	if status := ctx.Query("status"); status != "" {
+		s, err := strconv.Atoi(status)
+		if named, ok := model.ParseOrderStatus(status); ok {
+			s, err = named, nil
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的状态",
+			})
+			return
+		}
+		filter.Status = &s
+	}
+
+	orders, total, err := a.OrderService.ListOrders(filter, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取订单列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取订单列表成功",
+		"data": gin.H{
+			"orders":      orders,
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// GetOrder 后台订单详情，路径参数可以是订单ID或订单号
+func (a *AdminOrderController) GetOrder(ctx *gin.Context) {
+	order, err := a.OrderService.GetOrderByRef(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(orderLookupStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    order,
+	})
+}
+
+// CancelOrder 后台取消订单
+func (a *AdminOrderController) CancelOrder(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的订单ID",
+		})
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "请求参数错误",
+			})
+			return
+		}
+	}
+	if err := a.OrderService.AdminCancelOrder(getUserID(ctx), id, req.Reason); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "订单已取消",
+	})
+}
+
+// UpdateStatusRequest 后台修改订单履约状态
+type UpdateStatusRequest struct {
+	Status string `json:"status" binding:"required"` // packed、shipped、delivered 或 completed
+	Reason string `json:"reason"`
+}
+
+// UpdateStatus 后台推进订单履约状态，不符合状态流转规则时返回 409
+func (a *AdminOrderController) UpdateStatus(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的订单ID",
+		})
+		return
+	}
+	var req UpdateStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	status, ok := model.ParseOrderStatus(req.Status)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的订单状态",
+		})
+		return
+	}
+	order, err := a.OrderService.UpdateFulfilment(getUserID(ctx), id, status, req.Reason)
+	if err != nil {
+		ctx.JSON(orderErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "订单状态已更新",
+		"data":    order,
+	})
+}
+
+// orderErrorStatus 状态冲突返回 409，其余按请求错误处理
+func orderErrorStatus(err error) int {
+	var transitionErr *repository.OrderTransitionError
+	if errors.As(err, &transitionErr) || errors.Is(err, repository.ErrOrderStateChanged) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"bookstore-manager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminRefundController struct {
+	RefundService *service.RefundService
+}
+
+func NewAdminRefundController() *AdminRefundController {
+	return &AdminRefundController{
+		RefundService: service.NewRefundService(),
+	}
+}
+
+// ReviewRefundRequest 审核退款的备注
+type ReviewRefundRequest struct {
+	Remark string `json:"remark"`
+}
+
+// ListRefunds 后台退款单列表，支持按 status 筛选
+func (a *AdminRefundController) ListRefunds(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	refunds, total, err := a.RefundService.ListRefunds(ctx.Query("status"), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取退款列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取退款列表成功",
+		"data": gin.H{
+			"refunds":     refunds,
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// GetRefund 后台退款单详情
+func (a *AdminRefundController) GetRefund(ctx *gin.Context) {
+	id, ok := parseRefundID(ctx)
+	if !ok {
+		return
+	}
+	refund, err := a.RefundService.GetRefund(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    refund,
+	})
+}
+
+// ApproveRefund 同意退款并通过支付渠道原路退回
+func (a *AdminRefundController) ApproveRefund(ctx *gin.Context) {
+	a.review(ctx, a.RefundService.ApproveRefund, "已同意退款")
+}
+
+// RejectRefund 驳回退款申请
+func (a *AdminRefundController) RejectRefund(ctx *gin.Context) {
+	a.review(ctx, a.RefundService.RejectRefund, "已驳回退款申请")
+}
+
+// review 同意和驳回共用的参数解析和响应
+func (a *AdminRefundController) review(ctx *gin.Context, action func(adminID, refundID int64, remark string) (*model.Refund, error), message string) {
+	id, ok := parseRefundID(ctx)
+	if !ok {
+		return
+	}
+	var req ReviewRefundRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "请求参数错误",
+			})
+			return
+		}
+	}
+	refund, err := action(getUserID(ctx), id, req.Remark)
+	if err != nil {
+		status := http.StatusBadRequest
+		var transitionErr *repository.OrderTransitionError
+		if errors.Is(err, repository.ErrRefundStateChanged) || errors.As(err, &transitionErr) {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": message,
+		"data":    refund,
+	})
+}
+
+func parseRefundID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的退款单ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"bookstore-manager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminRoleController struct {
+	RBACService *service.RBACService
+}
+
+func NewAdminRoleController() *AdminRoleController {
+	return &AdminRoleController{
+		RBACService: service.NewRBACService(),
+	}
+}
+
+// ListPermissions 所有权限点
+func (a *AdminRoleController) ListPermissions(ctx *gin.Context) {
+	permissions, err := a.RBACService.ListPermissions()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取权限列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    permissions,
+	})
+}
+
+// GetMyPermissions 当前登录管理员的权限，前端据此控制菜单显示
+func (a *AdminRoleController) GetMyPermissions(ctx *gin.Context) {
+	permissions, err := a.RBACService.GetUserPermissions(getUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取权限失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    permissions,
+	})
+}
+
+// ListRoles 角色列表（包含权限）
+func (a *AdminRoleController) ListRoles(ctx *gin.Context) {
+	roles, err := a.RBACService.ListRoles()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取角色列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    roles,
+	})
+}
+
+// CreateRole 新增角色
+func (a *AdminRoleController) CreateRole(ctx *gin.Context) {
+	var req service.RoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	role, err := a.RBACService.CreateRole(&req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "新增角色成功",
+		"data":    role,
+	})
+}
+
+// UpdateRole 修改角色及其权限
+func (a *AdminRoleController) UpdateRole(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的角色ID",
+		})
+		return
+	}
+	var req service.RoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	role, err := a.RBACService.UpdateRole(id, &req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新角色成功",
+		"data":    role,
+	})
+}
+
+// DeleteRole 删除自定义角色
+func (a *AdminRoleController) DeleteRole(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的角色ID",
+		})
+		return
+	}
+	if err := a.RBACService.DeleteRole(id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "删除角色成功",
+	})
+}
+
+// AssignUserRoles 设置用户的角色
+func (a *AdminRoleController) AssignUserRoles(ctx *gin.Context) {
+	userID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	var req struct {
+		RoleIDs []string `json:"role_ids"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	roleIDs := make([]int64, 0, len(req.RoleIDs))
+	for _, idStr := range req.RoleIDs {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的角色ID",
+			})
+			return
+		}
+		roleIDs = append(roleIDs, id)
+	}
+	if err := a.RBACService.AssignUserRoles(getUserID(ctx), userID, roleIDs); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrOperatorForbidden) {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "设置角色成功",
+	})
+}
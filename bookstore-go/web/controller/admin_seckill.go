@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"bookstore-manager/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminSeckillController struct {
+	SeckillService *service.SeckillService
+}
+
+func NewAdminSeckillController() *AdminSeckillController {
+	return &AdminSeckillController{
+		SeckillService: service.NewSeckillService(),
+	}
+}
+
+// ListCampaigns 后台秒杀活动列表，支持按 status 筛选
+func (a *AdminSeckillController) ListCampaigns(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	var status *int
+	if s := ctx.Query("status"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的状态",
+			})
+			return
+		}
+		status = &v
+	}
+	campaigns, total, err := a.SeckillService.ListCampaigns(status, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取秒杀活动列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取秒杀活动列表成功",
+		"data": gin.H{
+			"campaigns":   campaigns,
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// GetCampaign 活动详情，含剩余库存
+func (a *AdminSeckillController) GetCampaign(ctx *gin.Context) {
+	id, ok := parseCampaignID(ctx)
+	if !ok {
+		return
+	}
+	campaign, err := a.SeckillService.GetCampaign(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    campaign,
+	})
+}
+
+// CreateCampaign 创建秒杀活动
+func (a *AdminSeckillController) CreateCampaign(ctx *gin.Context) {
+	var req service.SeckillCampaignRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	campaign, err := a.SeckillService.CreateCampaign(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "创建秒杀活动成功",
+		"data":    campaign,
+	})
+}
+
+// UpdateCampaign 修改未开始的秒杀活动
+func (a *AdminSeckillController) UpdateCampaign(ctx *gin.Context) {
+	id, ok := parseCampaignID(ctx)
+	if !ok {
+		return
+	}
+	var req service.SeckillCampaignRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	campaign, err := a.SeckillService.UpdateCampaign(id, &req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "修改秒杀活动成功",
+		"data":    campaign,
+	})
+}
+
+// UpdateCampaignStatus 启用或停用秒杀活动
+func (a *AdminSeckillController) UpdateCampaignStatus(ctx *gin.Context) {
+	id, ok := parseCampaignID(ctx)
+	if !ok {
+		return
+	}
+	var req struct {
+		Status *int `json:"status"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Status == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	if err := a.SeckillService.UpdateCampaignStatus(id, *req.Status); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新活动状态成功",
+	})
+}
+
+func parseCampaignID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的活动ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,339 @@
+package controller
+
+import (
+	"bookstore-manager/model"
+	"bookstore-manager/repository"
+	"bookstore-manager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminUserController struct {
+	AdminUserService *service.AdminUserService
+}
+
+func NewAdminUserController() *AdminUserController {
+	return &AdminUserController{
+		AdminUserService: service.NewAdminUserService(),
+	}
+}
+
+// ListUsers 后台用户列表，支持 keyword/username/email/is_admin/status 筛选
+func (a *AdminUserController) ListUsers(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	filter := &repository.UserFilter{
+		Keyword:  ctx.Query("keyword"),
+		Username: ctx.Query("username"),
+		Email:    ctx.Query("email"),
+	}
+	if isAdmin := ctx.Query("is_admin"); isAdmin != "" {
+		v, err := strconv.ParseBool(isAdmin)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的is_admin参数",
+			})
+			return
+		}
+		filter.IsAdmin = &v
+	}
+	if status := ctx.Query("status"); status != "" {
+		v, err := strconv.Atoi(status)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的状态",
+			})
+			return
+		}
+		filter.Status = &v
+	}
+
+	users, total, err := a.AdminUserService.ListUsers(filter, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取用户列表失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取用户列表成功",
+		"data": gin.H{
+			"users":       users,
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// GetUser 用户详情
+func (a *AdminUserController) GetUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	user, err := a.AdminUserService.GetUser(id)
+	if err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取用户信息成功",
+		"data":    user,
+	})
+}
+
+// CreateUser 后台创建用户
+func (a *AdminUserController) CreateUser(ctx *gin.Context) {
+	var req service.AdminCreateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	user, err := a.AdminUserService.CreateUser(getUserID(ctx), &req)
+	if err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "创建用户成功",
+		"data":    user,
+	})
+}
+
+// UpdateUser 编辑用户资料/管理员权限
+func (a *AdminUserController) UpdateUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	var req service.AdminUpdateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	user, err := a.AdminUserService.UpdateUser(getUserID(ctx), id, &req)
+	if err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新用户成功",
+		"data":    user,
+	})
+}
+
+// UpdateUserStatus 启用/禁用用户
+func (a *AdminUserController) UpdateUserStatus(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	var req struct {
+		Status *int `json:"status"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Status == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	user, err := a.AdminUserService.UpdateUserStatus(getUserID(ctx), id, *req.Status)
+	if err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新用户状态成功",
+		"data":    user,
+	})
+}
+
+// SetUserAdmin 设置/取消管理员
+func (a *AdminUserController) SetUserAdmin(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	var req struct {
+		IsAdmin *bool `json:"is_admin"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.IsAdmin == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	user, err := a.AdminUserService.SetUserAdmin(getUserID(ctx), id, *req.IsAdmin)
+	if err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新管理员权限成功",
+		"data":    user,
+	})
+}
+
+// DeleteUser 删除用户。用户关联着订单、支付等历史数据，这里只做禁用并撤销其 token
+func (a *AdminUserController) DeleteUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	if _, err := a.AdminUserService.UpdateUserStatus(getUserID(ctx), id, model.UserStatusDisabled); err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "用户已禁用",
+	})
+}
+
+// ResetTwoFactor 关闭用户的两步验证
+func (a *AdminUserController) ResetTwoFactor(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	if err := a.AdminUserService.ResetTwoFactor(getUserID(ctx), id); err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已关闭该用户的两步验证",
+	})
+}
+
+// UnlockUser 解除用户因登录失败过多造成的锁定
+func (a *AdminUserController) UnlockUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+	if err := a.AdminUserService.UnlockUser(id); err != nil {
+		ctx.JSON(adminUserErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已解除锁定",
+	})
+}
+
+// adminUserErrorStatus 越权修改返回 403，用户不存在返回 404，参数不合法返回 400，用户名/邮箱等冲突返回 409，
+// 数据库等其他错误返回 500
+func adminUserErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrOperatorForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidUserStatus),
+		errors.Is(err, service.ErrDisableSelf),
+		errors.Is(err, service.ErrRevokeSelfAdmin):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrUserExists), errors.Is(err, service.ErrContactTaken):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,262 @@
+package controller
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// cartIDHeader 未登录时用于标识匿名购物车的请求头
+const cartIDHeader = "X-Cart-ID"
+
+type CartController struct {
+	CartService *service.CartService
+}
+
+func NewCartController() *CartController {
+	return &CartController{
+		CartService: service.NewCartService(),
+	}
+}
+
+// cartOwner 已登录时使用用户购物车，否则使用请求头中的匿名购物车ID；
+// create 为 true 且没有匿名购物车ID时生成一个新的
+func cartOwner(ctx *gin.Context, create bool) *service.CartOwner {
+	if userID := getUserID(ctx); userID > 0 {
+		return &service.CartOwner{UserID: userID}
+	}
+	cartID := ctx.GetHeader(cartIDHeader)
+	if cartID == "" && create {
+		cartID = service.NewAnonCartID()
+	}
+	return &service.CartOwner{CartID: cartID}
+}
+
+func cartErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidCartID) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// GetCart 查看购物车
+func (c *CartController) GetCart(ctx *gin.Context) {
+	owner := cartOwner(ctx, false)
+	if owner.UserID == 0 && owner.CartID == "" {
+		// 还没有匿名购物车，直接返回空购物车
+		ctx.JSON(http.StatusOK, gin.H{
+			"code":    0,
+			"message": "success",
+			"data":    &service.Cart{Items: []*service.CartItem{}},
+		})
+		return
+	}
+	cart, err := c.CartService.GetCart(owner)
+	if err != nil {
+		ctx.JSON(cartErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": "获取购物车失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    cart,
+	})
+}
+
+// AddItem 加入购物车
+func (c *CartController) AddItem(ctx *gin.Context) {
+	var req struct {
+		BookID   int64 `json:"book_id,string"`
+		Quantity int   `json:"quantity"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.BookID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	cart, err := c.CartService.AddItem(cartOwner(ctx, true), req.BookID, req.Quantity)
+	if err != nil {
+		ctx.JSON(cartErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已加入购物车",
+		"data":    cart,
+	})
+}
+
+// UpdateItem 修改商品数量
+func (c *CartController) UpdateItem(ctx *gin.Context) {
+	bookID, err := strconv.ParseInt(ctx.Param("bookId"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的图书ID",
+		})
+		return
+	}
+	var req struct {
+		Quantity *int `json:"quantity"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Quantity == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	cart, err := c.CartService.UpdateItem(cartOwner(ctx, false), bookID, *req.Quantity)
+	if err != nil {
+		ctx.JSON(cartErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "更新成功",
+		"data":    cart,
+	})
+}
+
+// RemoveItem 从购物车移除商品
+func (c *CartController) RemoveItem(ctx *gin.Context) {
+	bookID, err := strconv.ParseInt(ctx.Param("bookId"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的图书ID",
+		})
+		return
+	}
+	cart, err := c.CartService.RemoveItem(cartOwner(ctx, false), bookID)
+	if err != nil {
+		ctx.JSON(cartErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已移除",
+		"data":    cart,
+	})
+}
+
+// ClearCart 清空购物车
+func (c *CartController) ClearCart(ctx *gin.Context) {
+	if err := c.CartService.ClearCart(cartOwner(ctx, false)); err != nil {
+		ctx.JSON(cartErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "购物车已清空",
+	})
+}
+
+// MergeCart 把请求头中的匿名购物车合并到当前登录用户的购物车
+func (c *CartController) MergeCart(ctx *gin.Context) {
+	userID := getUserID(ctx)
+	if err := c.CartService.MergeAnonCart(userID, ctx.GetHeader(cartIDHeader)); err != nil {
+		ctx.JSON(cartErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	cart, err := c.CartService.GetCart(&service.CartOwner{UserID: userID})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取购物车失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "购物车已合并",
+		"data":    cart,
+	})
+}
+
+// Checkout 购物车结算下单，book_ids 为空时结算全部可购买的商品
+func (c *CartController) Checkout(ctx *gin.Context) {
+	var req struct {
+		BookIDs []string `json:"book_ids"`
+	}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "请求参数错误",
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+	bookIDs := make([]int64, 0, len(req.BookIDs))
+	for _, idStr := range req.BookIDs {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "无效的图书ID",
+			})
+			return
+		}
+		bookIDs = append(bookIDs, id)
+	}
+	order, err := c.CartService.Checkout(getUserID(ctx), bookIDs)
+	if respondPriceChanged(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "结算失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "下单成功",
+		"data":    order,
+	})
+}
+
+// mergeCartOnLogin 登录成功后自动合并请求头中的匿名购物车，失败不影响登录
+func mergeCartOnLogin(ctx *gin.Context, response *service.LoginResponse) {
+	cartID := ctx.GetHeader(cartIDHeader)
+	if cartID == "" || response == nil || response.UserInfo == nil {
+		return
+	}
+	if err := service.NewCartService().MergeAnonCart(response.UserInfo.ID, cartID); err != nil {
+		global.Logger.Warn("登录后合并购物车失败", zap.Error(err), zap.Int64("userID", response.UserInfo.ID))
+	}
+}
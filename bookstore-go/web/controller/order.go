@@ -2,6 +2,8 @@ package controller
 
 import (
 	"bookstore-manager/service"
+	"bookstore-manager/utils/orderno"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -9,12 +11,16 @@ import (
 )
 
 type OrderController struct {
-	OrderService *service.OrderService
+	OrderService   *service.OrderService
+	PaymentService *service.PaymentService
+	RefundService  *service.RefundService
 }
 
 func NewOrderController() *OrderController {
 	return &OrderController{
-		OrderService: service.NewOrderService(),
+		OrderService:   service.NewOrderService(),
+		PaymentService: service.NewPaymentService(),
+		RefundService:  service.NewRefundService(),
 	}
 }
 
@@ -52,6 +58,9 @@ func (o *OrderController) CreateOrder(ctx *gin.Context) {
 	}
 
 	order, err := o.OrderService.CreateOrder(&req)
+	if respondPriceChanged(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    -1,
@@ -67,6 +76,22 @@ func (o *OrderController) CreateOrder(ctx *gin.Context) {
 	})
 }
 
+// respondPriceChanged 价格变动时返回 409 和最新总价，返回 true 表示已处理
+func respondPriceChanged(ctx *gin.Context, err error) bool {
+	var priceErr *service.PriceChangedError
+	if !errors.As(err, &priceErr) {
+		return false
+	}
+	ctx.JSON(http.StatusConflict, gin.H{
+		"code":    -1,
+		"message": priceErr.Error(),
+		"data": gin.H{
+			"total_amount": priceErr.ServerTotal,
+		},
+	})
+	return true
+}
+
 // GetUserOrders 获取订单列表
 func (o *OrderController) GetUserOrders(ctx *gin.Context) {
 	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
@@ -115,7 +140,7 @@ func (o *OrderController) GetUserOrders(ctx *gin.Context) {
 	})
 }
 
-// PayOrder 支付
+// PayOrder 发起支付，返回收银台地址；订单在支付渠道回调确认付款后才会变为已支付
 func (o *OrderController) PayOrder(ctx *gin.Context) {
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
@@ -125,7 +150,7 @@ func (o *OrderController) PayOrder(ctx *gin.Context) {
 		})
 		return
 	}
-	err = o.OrderService.PayOrders(id)
+	pay, err := o.PaymentService.CreatePayment(getUserID(ctx), id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    -1,
@@ -136,20 +161,18 @@ func (o *OrderController) PayOrder(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "支付成功",
+		"message": "请在收银台完成支付",
+		"data":    pay,
 	})
 }
 
-// GetOrderDetail 获取订单详情接口
+// GetOrderDetail 获取订单详情接口，包含状态变更记录。路径参数可以是订单ID或订单号
 func (o *OrderController) GetOrderDetail(ctx *gin.Context) {
-	idstr := ctx.Param("id")
-	orderID, _ := strconv.ParseInt(idstr, 10, 64)
-
-	order, err := o.OrderService.GetOrder(orderID)
+	order, err := o.OrderService.GetUserOrderByRef(getUserID(ctx), ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
+		ctx.JSON(orderLookupStatus(err), gin.H{
 			"code":    -1,
-			"message": "获取订单失败",
+			"message": err.Error(),
 		})
 		return
 	}
@@ -160,6 +183,87 @@ func (o *OrderController) GetOrderDetail(ctx *gin.Context) {
 	})
 }
 
+// ConfirmReceipt 确认收货
+func (o *OrderController) ConfirmReceipt(ctx *gin.Context) {
+	orderID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的订单ID",
+		})
+		return
+	}
+	if err := o.OrderService.ConfirmReceipt(getUserID(ctx), orderID); err != nil {
+		ctx.JSON(orderErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已确认收货",
+	})
+}
+
+// RequestRefund 申请退款/退货，items 为空时退还订单中所有未退款的商品
+func (o *OrderController) RequestRefund(ctx *gin.Context) {
+	orderID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的订单ID",
+		})
+		return
+	}
+	var req service.RefundRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	refund, err := o.RefundService.RequestRefund(getUserID(ctx), orderID, &req)
+	if err != nil {
+		ctx.JSON(orderErrorStatus(err), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "退款申请已提交，请等待审核",
+		"data":    refund,
+	})
+}
+
+// ListRefunds 查看订单的退款进度
+func (o *OrderController) ListRefunds(ctx *gin.Context) {
+	orderID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的订单ID",
+		})
+		return
+	}
+	refunds, err := o.RefundService.ListOrderRefunds(getUserID(ctx), orderID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    refunds,
+	})
+}
+
 // CancelOrder 取消订单
 func (o OrderController) CancelOrder(ctx *gin.Context) {
 	// 获取路径参数 /order/:id/cancel
@@ -194,3 +298,11 @@ func (o OrderController) CancelOrder(ctx *gin.Context) {
 		"message": "订单已取消",
 	})
 }
+
+// orderLookupStatus 订单号格式错误返回 400，其余按订单不存在处理
+func orderLookupStatus(err error) int {
+	if errors.Is(err, orderno.ErrInvalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusNotFound
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"bookstore-manager/payment"
+	"bookstore-manager/service"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCallbackBody 回调请求体上限
+const maxCallbackBody = 64 << 10
+
+type PaymentController struct {
+	PaymentService *service.PaymentService
+}
+
+func NewPaymentController() *PaymentController {
+	return &PaymentController{
+		PaymentService: service.NewPaymentService(),
+	}
+}
+
+// GetPayment 查询支付单状态，前端在收银台返回后轮询这个接口
+func (p *PaymentController) GetPayment(ctx *gin.Context) {
+	pay, err := p.PaymentService.GetPayment(getUserID(ctx), ctx.Param("paymentNo"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    pay,
+	})
+}
+
+// Webhook 支付渠道回调，验签失败返回 401；处理失败返回 500，渠道会稍后重试
+func (p *PaymentController) Webhook(ctx *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxCallbackBody))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "读取回调内容失败",
+		})
+		return
+	}
+	err = p.PaymentService.HandleCallback(ctx.Param("provider"), ctx.Request.Header, body)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, gin.H{
+			"code":    0,
+			"message": "success",
+		})
+	case errors.Is(err, payment.ErrInvalidSignature):
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrUnknownProvider):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "处理回调失败",
+			"error":   err.Error(),
+		})
+	}
+}
+
+// MockCheckoutPage 模拟收银台，展示待支付的金额
+func (p *PaymentController) MockCheckoutPage(ctx *gin.Context) {
+	pay, err := p.PaymentService.GetMockPayment(ctx.Param("paymentNo"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "POST 本地址并传入 result（success、fail 或 timeout）模拟付款结果",
+		"data": gin.H{
+			"payment_no": pay.PaymentNo,
+			"amount":     pay.Amount,
+			"status":     pay.Status,
+		},
+	})
+}
+
+// MockCheckout 模拟用户在收银台付款，结果通过回调异步通知
+func (p *PaymentController) MockCheckout(ctx *gin.Context) {
+	var req struct {
+		Result string `json:"result" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	if err := p.PaymentService.MockPay(ctx.Param("paymentNo"), req.Result); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrUnknownProvider) || errors.Is(err, payment.ErrTradeNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已提交，稍后将收到支付结果通知",
+	})
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"bookstore-manager/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SeckillController struct {
+	SeckillService *service.SeckillService
+}
+
+func NewSeckillController() *SeckillController {
+	return &SeckillController{
+		SeckillService: service.NewSeckillService(),
+	}
+}
+
+// SeckillPurchaseRequest 抢购数量，未传时为 1
+type SeckillPurchaseRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// ListCampaigns 进行中和即将开始的秒杀活动
+func (s *SeckillController) ListCampaigns(ctx *gin.Context) {
+	campaigns, err := s.SeckillService.ListOpenCampaigns()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取秒杀活动失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    campaigns,
+	})
+}
+
+// Purchase 参与秒杀。抢到名额后订单异步落库，返回 202 和订单号，客户端凭订单号轮询结果
+func (s *SeckillController) Purchase(ctx *gin.Context) {
+	campaignID, err := strconv.ParseInt(ctx.Param("campaignId"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "无效的活动ID",
+		})
+		return
+	}
+	var req SeckillPurchaseRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "请求参数错误",
+			})
+			return
+		}
+	}
+	orderNo, err := s.SeckillService.Purchase(getUserID(ctx), campaignID, req.Quantity)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrSeckillBusy) {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"code":    0,
+		"message": "抢购成功，订单处理中",
+		"data": gin.H{
+			"order_no": orderNo,
+			"status":   service.SeckillStatusPending,
+		},
+	})
+}
+
+// GetOrderResult 轮询抢购订单是否已经落库
+func (s *SeckillController) GetOrderResult(ctx *gin.Context) {
+	result, err := s.SeckillService.GetOrderResult(getUserID(ctx), ctx.Param("orderNo"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
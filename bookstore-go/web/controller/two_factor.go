@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"bookstore-manager/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorController struct {
+	TwoFactorService *service.TwoFactorService
+}
+
+func NewTwoFactorController() *TwoFactorController {
+	return &TwoFactorController{
+		TwoFactorService: service.NewTwoFactorService(),
+	}
+}
+
+type twoFactorCodeRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// Setup 生成两步验证密钥和二维码
+func (t *TwoFactorController) Setup(ctx *gin.Context) {
+	setup, err := t.TwoFactorService.Setup(getUserID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "请使用验证器扫描二维码",
+		"data":    setup,
+	})
+}
+
+// Enable 输入验证器上的动态码确认开启，返回恢复码
+func (t *TwoFactorController) Enable(ctx *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	codes, err := t.TwoFactorService.Enable(getUserID(ctx), req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "两步验证已开启，请妥善保存恢复码",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// Disable 关闭两步验证
+func (t *TwoFactorController) Disable(ctx *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	if err := t.TwoFactorService.Disable(getUserID(ctx), req.Password, req.Code); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "两步验证已关闭",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+func (t *TwoFactorController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	codes, err := t.TwoFactorService.RegenerateRecoveryCodes(getUserID(ctx), req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "恢复码已更新，旧的恢复码已失效",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"bookstore-manager/jwt"
 	"bookstore-manager/model"
 	"bookstore-manager/service"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,6 +38,7 @@ type LoginRequest struct {
 	Password     string `json:"password"`
 	CaptchaID    string `json:"captcha_id"`
 	CaptchaValue string `json:"captcha_value"`
+	DeviceName   string `json:"device_name"` //可选，客户端自定义的设备名称
 }
 
 func (u *UserController) UserRegister(ctx *gin.Context) {
@@ -103,22 +106,132 @@ func (u *UserController) UserLogin(ctx *gin.Context) {
 
 	//2、校验用户信息（是否有这个用户，密码是否正确）
 	//userSvc := service.NewUserService()
-	response, err := u.UserService.UserLogin(req.Username, req.Password)
+	response, err := u.UserService.UserLogin(req.Username, req.Password, sessionMeta(ctx, req.DeviceName))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
+		ctx.JSON(loginErrorStatus(err, http.StatusInternalServerError), gin.H{
 			"code":    -1,
 			"message": err.Error(),
 		})
 		return
 	}
+	mergeCartOnLogin(ctx, response)
 	//3、返回JWT给用户，后面发送请求就知道是哪个用户发送的了
 	ctx.JSON(200, gin.H{
+		"code":    0,
+		"data":    response,
+		"message": loginMessage(response),
+	})
+}
+
+// AdminLogin 后台登录，返回带管理员角色的token
+func (u *UserController) AdminLogin(ctx *gin.Context) {
+	var req LoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数绑定错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+	captchaSvc := service.NewCaptchaService()
+	if !captchaSvc.VerifyCaptcha(req.CaptchaID, req.CaptchaValue) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "验证码错误",
+		})
+		return
+	}
+
+	response, err := u.UserService.AdminLogin(req.Username, req.Password, sessionMeta(ctx, req.DeviceName))
+	if err != nil {
+		ctx.JSON(loginErrorStatus(err, http.StatusUnauthorized), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"data":    response,
+		"message": loginMessage(response),
+	})
+}
+
+// LoginTwoFactor 登录第二步，用登录接口返回的挑战token和动态码(或恢复码)换取正式token
+func (u *UserController) LoginTwoFactor(ctx *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.ChallengeToken == "" || req.Code == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	response, err := u.UserService.VerifyTwoFactorLogin(req.ChallengeToken, req.Code)
+	if err != nil {
+		ctx.JSON(loginErrorStatus(err, http.StatusUnauthorized), gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	mergeCartOnLogin(ctx, response)
+	ctx.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"data":    response,
 		"message": "登陆成功",
 	})
 }
 
+// loginErrorStatus 登录被限流时返回 429，其他错误使用调用方给定的状态码
+func loginErrorStatus(err error, fallback int) int {
+	var throttled *service.LoginThrottledError
+	if errors.As(err, &throttled) {
+		return http.StatusTooManyRequests
+	}
+	return fallback
+}
+
+func loginMessage(response *service.LoginResponse) string {
+	if response.TwoFactorRequired {
+		return "请输入两步验证码"
+	}
+	return "登陆成功"
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken 用刷新token换取新的access token，旧的刷新token随之失效
+func (u *UserController) RefreshToken(ctx *gin.Context) {
+	var req RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    -1,
+			"message": "请求参数错误",
+		})
+		return
+	}
+	response, err := u.UserService.RefreshToken(req.RefreshToken, sessionMeta(ctx, ""))
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"data":    response,
+		"message": "刷新成功",
+	})
+}
+
 func (u *UserController) GetUserProfile(ctx *gin.Context) {
 	userID, exists := ctx.Get("userID")
 	if !exists {
@@ -312,9 +425,9 @@ func (u *UserController) Logout(ctx *gin.Context) {
 		uid = v
 	}
 
-	//撤销用户token
-	err := jwt.RevokeToken(uid)
-	if err != nil {
+	//只撤销当前会话的token，其他设备上的登录不受影响
+	err := jwt.RevokeSession(uid, ctx.GetString("sessionID"))
+	if err != nil && !errors.Is(err, jwt.ErrSessionNotFound) {
 		ctx.JSON(500, gin.H{
 			"code":    -1,
 			"message": "退出登录失败",
@@ -322,4 +435,111 @@ func (u *UserController) Logout(ctx *gin.Context) {
 		})
 		return
 	}
+	ctx.JSON(200, gin.H{
+		"code":    0,
+		"message": "退出登录成功",
+	})
+}
+
+// ListSessions 当前用户已登录的设备列表
+func (u *UserController) ListSessions(ctx *gin.Context) {
+	sessions, err := u.UserService.ListSessions(getUserID(ctx), ctx.GetString("sessionID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": "获取登录设备失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    sessions,
+	})
+}
+
+// RevokeSession 退出指定设备
+func (u *UserController) RevokeSession(ctx *gin.Context) {
+	err := u.UserService.RevokeSession(getUserID(ctx), ctx.Param("id"))
+	if errors.Is(err, jwt.ErrSessionNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已退出该设备",
+	})
+}
+
+// RevokeOtherSessions 退出除当前设备以外的所有设备
+func (u *UserController) RevokeOtherSessions(ctx *gin.Context) {
+	count, err := u.UserService.RevokeOtherSessions(getUserID(ctx), ctx.GetString("sessionID"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    -1,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已退出其他设备",
+		"data": gin.H{
+			"revoked": count,
+		},
+	})
+}
+
+// GetJWKS 公开签名公钥(JWKS格式)，其他服务据此离线校验我们签发的token。
+// 按标准格式直接返回，不包裹 code/message
+func (u *UserController) GetJWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, jwt.PublicJWKS())
+}
+
+// sessionMeta 收集当前请求的设备信息，客户端没有提供设备名称时根据 User-Agent 推断
+func sessionMeta(ctx *gin.Context, deviceName string) *jwt.SessionMeta {
+	userAgent := ctx.Request.UserAgent()
+	deviceName = strings.TrimSpace(deviceName)
+	if deviceName == "" {
+		deviceName = guessDeviceName(userAgent)
+	}
+	if len(deviceName) > 64 {
+		deviceName = deviceName[:64]
+	}
+	return &jwt.SessionMeta{
+		DeviceName: deviceName,
+		IP:         ctx.ClientIP(),
+		UserAgent:  userAgent,
+	}
+}
+
+func guessDeviceName(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone"):
+		return "iPhone"
+	case strings.Contains(userAgent, "iPad"):
+		return "iPad"
+	case strings.Contains(userAgent, "Android"):
+		return "Android"
+	case strings.Contains(userAgent, "Windows"):
+		return "Windows"
+	case strings.Contains(userAgent, "Macintosh"):
+		return "Mac"
+	case strings.Contains(userAgent, "Linux"):
+		return "Linux"
+	default:
+		return "未知设备"
+	}
 }
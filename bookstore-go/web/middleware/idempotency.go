@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bookstore-manager/global"
+	"bookstore-manager/service"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端为每次业务操作生成的唯一键，重试时带上同一个键
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader 响应是重放的第一次请求结果时带上这个头
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 128
+)
+
+// idempotencyRecorder 记录响应体，处理完成后保存到 Redis
+type idempotencyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency 幂等中间件，需放在登录认证之后使用。请求带 Idempotency-Key 时：
+// 窗口期内同一个键的重复请求直接返回第一次的响应；同一个键但请求内容不同返回 409；
+// 第一次请求还在处理时返回 409。不带这个头的请求照常处理
+func Idempotency(ttl time.Duration) gin.HandlerFunc {
+	store := service.NewIdempotencyStore(ttl)
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "Idempotency-Key 过长",
+			})
+			ctx.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    -1,
+				"message": "读取请求失败",
+			})
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := strconv.Itoa(ctx.GetInt("userID"))
+		fingerprint := store.Fingerprint(ctx.Request.Method, ctx.Request.URL.Path, body)
+		record, acquired, err := store.Begin(scope, key, fingerprint)
+		if err != nil {
+			// Redis 故障时不阻塞下单，退化为不做幂等控制
+			global.Logger.Error("读取幂等键失败", zap.Error(err))
+			ctx.Next()
+			return
+		}
+		if !acquired {
+			replayIdempotent(ctx, record, fingerprint)
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+		ctx.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			err = store.Release(scope, key)
+		} else {
+			err = store.Complete(scope, key, &service.IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: recorder.Header().Get("Content-Type"),
+				Body:        recorder.body.String(),
+			})
+		}
+		if err != nil {
+			global.Logger.Error("保存幂等键失败", zap.Error(err))
+		}
+	}
+}
+
+func replayIdempotent(ctx *gin.Context, record *service.IdempotencyRecord, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code":    -1,
+			"message": "Idempotency-Key 已被用于内容不同的请求",
+		})
+	case !record.Completed:
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code":    -1,
+			"message": "相同的请求正在处理中，请稍后重试",
+		})
+	default:
+		ctx.Header(idempotencyReplayedHeader, "true")
+		ctx.Data(record.Status, record.ContentType, []byte(record.Body))
+	}
+	ctx.Abort()
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"bookstore-manager/jwt"
+	"bookstore-manager/service"
 	"net/http"
 	"strings"
 
@@ -14,61 +15,99 @@ import (
 // JWTAuthMiddleware JWT认证中间件
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		// 从请求头获取token
-		authHeader := ctx.GetHeader("Authorization")
-		if authHeader == "" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"code":    1,
-				"message": "请求头中缺少Authorization字段",
-			})
-			ctx.Abort()
+		claims, ok := parseAccessToken(ctx)
+		if !ok {
 			return
 		}
 
-		//检查Bearer前缀
-		tokenParts := strings.SplitN(authHeader, " ", 2)
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"code":    -1,
-				"message": "Authorization格式错误,应为:Bearer {token}",
-			})
-			ctx.Abort()
-			return
-		}
+		//将用户信息存储到上下文中
+		ctx.Set("userID", int(claims.UserID))
+		ctx.Set("username", claims.Username)
+		ctx.Set("role", claims.Role)
+		ctx.Set("sessionID", claims.SessionID)
 
-		tokenString := tokenParts[1]
+		//继续处理请求
+		ctx.Next()
+	}
+}
 
-		// 解析并验证token
-		claims, err := jwt.ParseToken(tokenString)
-		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"code":    -1,
-				"message": "无效token",
-				"error":   err.Error(),
-			})
-			ctx.Abort()
+// AdminAuthMiddleware 后台认证中间件，只放行通过后台登录接口签发的管理员token
+func AdminAuthMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := parseAccessToken(ctx)
+		if !ok {
 			return
 		}
 
-		//检查token类型，只许access token访问API
-		if claims.TokenType != "access" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
+		//普通用户的token即使账号是管理员也不能访问后台，必须走后台登录
+		if claims.Role != jwt.RoleAdmin {
+			ctx.JSON(http.StatusForbidden, gin.H{
 				"code":    -1,
-				"message": "token类型错误,请使用access token",
+				"message": "无后台访问权限",
 			})
 			ctx.Abort()
 			return
 		}
 
-		//将用户信息存储到上下文中
 		ctx.Set("userID", int(claims.UserID))
 		ctx.Set("username", claims.Username)
+		ctx.Set("role", claims.Role)
+		ctx.Set("sessionID", claims.SessionID)
 
-		//继续处理请求
 		ctx.Next()
 	}
 }
 
+// parseAccessToken 从请求头解析并校验access token，失败时写入错误响应并中断请求
+func parseAccessToken(ctx *gin.Context) (*jwt.Claims, bool) {
+	// 从请求头获取token
+	authHeader := ctx.GetHeader("Authorization")
+	if authHeader == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    1,
+			"message": "请求头中缺少Authorization字段",
+		})
+		ctx.Abort()
+		return nil, false
+	}
+
+	//检查Bearer前缀
+	tokenParts := strings.SplitN(authHeader, " ", 2)
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    -1,
+			"message": "Authorization格式错误,应为:Bearer {token}",
+		})
+		ctx.Abort()
+		return nil, false
+	}
+
+	tokenString := tokenParts[1]
+
+	// 解析并验证token
+	claims, err := jwt.ParseToken(tokenString)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    -1,
+			"message": "无效token",
+			"error":   err.Error(),
+		})
+		ctx.Abort()
+		return nil, false
+	}
+
+	//检查token类型，只许access token访问API
+	if claims.TokenType != "access" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    -1,
+			"message": "token类型错误,请使用access token",
+		})
+		ctx.Abort()
+		return nil, false
+	}
+	return claims, true
+}
+
 // 可选认证中间件（用于可选登录的接口）
 func OptionalAuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -101,8 +140,37 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			//将用户信息存储到上下文中
 			ctx.Set("userID", int(claims.UserID))
 			ctx.Set("username", claims.Username)
+			ctx.Set("role", claims.Role)
+			ctx.Set("sessionID", claims.SessionID)
 			ctx.Set("authenticated", true)
 		}
 		ctx.Next()
 	}
 }
+
+// RequirePermission 权限校验中间件，需放在 AdminAuthMiddleware 之后使用
+func RequirePermission(code string) gin.HandlerFunc {
+	rbac := service.NewRBACService()
+	return func(ctx *gin.Context) {
+		userID := ctx.GetInt("userID")
+		ok, err := rbac.HasPermission(int64(userID), code)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    -1,
+				"message": "权限校验失败",
+				"error":   err.Error(),
+			})
+			ctx.Abort()
+			return
+		}
+		if !ok {
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"code":    -1,
+				"message": "没有该操作的权限",
+			})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -1,23 +1,36 @@
 package router
 
 import (
+	"bookstore-manager/config"
+	"bookstore-manager/global"
+	"bookstore-manager/model"
+	"bookstore-manager/payment"
 	"bookstore-manager/repository"
 	"bookstore-manager/service"
 	"bookstore-manager/web/controller"
 	"bookstore-manager/web/middleware"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
+// idempotencyWindow 幂等键保留时长，期间同一个键的重试返回第一次的结果
+const idempotencyWindow = 24 * time.Hour
+
 func InitRouter() *gin.Engine {
 	r := gin.Default()
+	// gin 默认信任所有代理，客户端可以伪造 X-Forwarded-For 绕过按 IP 的限流，这里只信任配置的代理
+	if err := r.SetTrustedProxies(config.AppConfig.Server.TrustedProxies); err != nil {
+		global.Logger.Fatal("可信代理配置错误", zap.Error(err))
+	}
 	// 添加CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, X-Requested-With, Cache-Control, X-Api-Key")
-		c.Header("Access-Control-Expose-Headers", "Content-Length")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, X-Requested-With, Cache-Control, X-Api-Key, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, Idempotent-Replayed")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		// 处理预检请求
@@ -29,19 +42,39 @@ func InitRouter() *gin.Engine {
 		c.Next()
 	})
 	userController := controller.NewUserController()
+	accountController := controller.NewAccountController()
+	twoFactorController := controller.NewTwoFactorController()
 	captchController := controller.NewCaptchController()
 	bookController := controller.NewBookController()
 	favoriteDAO := repository.NewFavoriteDAO()
 	favoriteService := service.NewFavoriteService(favoriteDAO)
 	favoriteController := controller.NewFavoriteController(favoriteService)
 	orderController := controller.NewOrderController()
+	// 下单、支付等请求在窗口期内按 Idempotency-Key 去重
+	idempotent := middleware.Idempotency(idempotencyWindow)
+	cartController := controller.NewCartController()
 	categoryController := controller.NewCategoryController()
+	adminBookController := controller.NewAdminBookController()
+	adminCategoryController := controller.NewAdminCategoryController()
+	adminUserController := controller.NewAdminUserController()
+	adminDashboardController := controller.NewAdminDashboardController()
+	adminOrderController := controller.NewAdminOrderController()
+	paymentController := controller.NewPaymentController()
+	adminRefundController := controller.NewAdminRefundController()
+	seckillController := controller.NewSeckillController()
+	adminSeckillController := controller.NewAdminSeckillController()
+	adminRoleController := controller.NewAdminRoleController()
 	v1 := r.Group("/api/v1")
 	{
 		user := v1.Group("/user")
 		{
 			user.POST("/register", userController.UserRegister)
 			user.POST("/login", userController.UserLogin)
+			user.POST("/login/2fa", userController.LoginTwoFactor)
+			user.POST("/refresh", userController.RefreshToken)
+			user.POST("/password/forgot", accountController.ForgotPassword)
+			user.POST("/password/reset", accountController.ResetPassword)
+			user.POST("/email/verify", accountController.VerifyEmail)
 		}
 		auth := user.Group("")
 		{
@@ -51,6 +84,14 @@ func InitRouter() *gin.Engine {
 				auth.PUT("/profile", userController.UpdateUserProfile)
 				auth.PUT("/password", userController.ChangePassword)
 				auth.DELETE("logout", userController.Logout)
+				auth.POST("/email/verification", accountController.SendEmailVerification)
+				auth.POST("/2fa/setup", twoFactorController.Setup)
+				auth.POST("/2fa/enable", twoFactorController.Enable)
+				auth.POST("/2fa/disable", twoFactorController.Disable)
+				auth.POST("/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes)
+				auth.GET("/sessions", userController.ListSessions)
+				auth.DELETE("/sessions/others", userController.RevokeOtherSessions)
+				auth.DELETE("/sessions/:id", userController.RevokeSession)
 			}
 		}
 
@@ -79,22 +120,156 @@ func InitRouter() *gin.Engine {
 			favorite.GET("/:id/check", favoriteController.CheckFavorite)
 		}
 
+		// 购物车：未登录时通过 X-Cart-ID 请求头使用匿名购物车
+		cart := v1.Group("/cart")
+		cart.Use(middleware.OptionalAuthMiddleware())
+		{
+			cart.GET("", cartController.GetCart)
+			cart.POST("/items", cartController.AddItem)
+			cart.PUT("/items/:bookId", cartController.UpdateItem)
+			cart.DELETE("/items/:bookId", cartController.RemoveItem)
+			cart.DELETE("", cartController.ClearCart)
+		}
+		cartAuth := v1.Group("/cart")
+		cartAuth.Use(middleware.JWTAuthMiddleware())
+		{
+			cartAuth.POST("/merge", cartController.MergeCart)
+			cartAuth.POST("/checkout", idempotent, cartController.Checkout)
+		}
+
 		order := v1.Group("/order")
 		order.Use(middleware.JWTAuthMiddleware())
 		{
-			order.POST("/create", orderController.CreateOrder)
+			order.POST("/create", idempotent, orderController.CreateOrder)
 			order.GET("/list", orderController.GetUserOrders)
-			order.POST("/:id/pay", orderController.PayOrder)
+			order.POST("/:id/pay", idempotent, orderController.PayOrder)
 			order.POST("/:id/cancel", orderController.CancelOrder)
+			order.POST("/:id/confirm", orderController.ConfirmReceipt)
+			order.POST("/:id/refunds", orderController.RequestRefund)
+			order.GET("/:id/refunds", orderController.ListRefunds)
 			order.GET("/:id", orderController.GetOrderDetail)
 		}
 
+		// 秒杀：抢到名额后订单异步落库，凭订单号轮询结果
+		seckill := v1.Group("/seckill")
+		{
+			seckill.GET("/campaigns", seckillController.ListCampaigns)
+			seckill.POST("/:campaignId", middleware.JWTAuthMiddleware(), idempotent, seckillController.Purchase)
+			seckill.GET("/orders/:orderNo", middleware.JWTAuthMiddleware(), seckillController.GetOrderResult)
+		}
+
+		// 支付：回调和模拟收银台由支付渠道/浏览器直接访问，不需要登录
+		pay := v1.Group("/payment")
+		{
+			pay.POST("/webhook/:provider", paymentController.Webhook)
+			// 模拟收银台不需要登录，只在开发环境启用 mock 渠道时注册
+			if payment.MockActive() {
+				pay.GET("/mock/:paymentNo", paymentController.MockCheckoutPage)
+				pay.POST("/mock/:paymentNo", paymentController.MockCheckout)
+			}
+			pay.GET("/status/:paymentNo", middleware.JWTAuthMiddleware(), paymentController.GetPayment)
+		}
+
+		admin := v1.Group("/admin")
+		{
+			admin.POST("/auth/login", userController.AdminLogin)
+			admin.POST("/auth/login/2fa", userController.LoginTwoFactor)
+		}
+		adminAuth := admin.Group("")
+		adminAuth.Use(middleware.AdminAuthMiddleware())
+		{
+			adminAuth.GET("/permissions/mine", adminRoleController.GetMyPermissions)
+			adminAuth.GET("/dashboard/stats", middleware.RequirePermission(model.PermDashboardView), adminDashboardController.GetStats)
+
+			bookView := middleware.RequirePermission(model.PermBookView)
+			bookEdit := middleware.RequirePermission(model.PermBookEdit)
+			adminBook := adminAuth.Group("/books")
+			{
+				adminBook.GET("/list", bookView, adminBookController.ListBooks)
+				adminBook.POST("/create", bookEdit, adminBookController.CreateBook)
+				adminBook.GET("/:id", bookView, adminBookController.GetBook)
+				adminBook.PUT("/:id", bookEdit, adminBookController.UpdateBook)
+				adminBook.DELETE("/:id", bookEdit, adminBookController.DeleteBook)
+				adminBook.PUT("/:id/status", bookEdit, adminBookController.UpdateBookStatus)
+			}
+
+			categoryView := middleware.RequirePermission(model.PermCategoryView)
+			categoryEdit := middleware.RequirePermission(model.PermCategoryEdit)
+			adminCategory := adminAuth.Group("/categories")
+			{
+				adminCategory.GET("/list", categoryView, adminCategoryController.ListCategories)
+				adminCategory.POST("/create", categoryEdit, adminCategoryController.CreateCategory)
+				adminCategory.PUT("/reorder", categoryEdit, adminCategoryController.ReorderCategories)
+				adminCategory.GET("/:id", categoryView, adminCategoryController.GetCategory)
+				adminCategory.PUT("/:id", categoryEdit, adminCategoryController.UpdateCategory)
+				adminCategory.DELETE("/:id", categoryEdit, adminCategoryController.DeleteCategory)
+			}
+
+			userView := middleware.RequirePermission(model.PermUserView)
+			userEdit := middleware.RequirePermission(model.PermUserEdit)
+			roleManage := middleware.RequirePermission(model.PermRoleManage)
+			adminUser := adminAuth.Group("/users")
+			{
+				adminUser.GET("/list", userView, adminUserController.ListUsers)
+				adminUser.POST("/create", userEdit, adminUserController.CreateUser)
+				adminUser.GET("/:id", userView, adminUserController.GetUser)
+				adminUser.PUT("/:id", userEdit, adminUserController.UpdateUser)
+				adminUser.PUT("/:id/status", userEdit, adminUserController.UpdateUserStatus)
+				adminUser.PUT("/:id/admin", userEdit, adminUserController.SetUserAdmin)
+				adminUser.DELETE("/:id", userEdit, adminUserController.DeleteUser)
+				adminUser.PUT("/:id/roles", roleManage, adminRoleController.AssignUserRoles)
+				adminUser.DELETE("/:id/2fa", userEdit, adminUserController.ResetTwoFactor)
+				adminUser.DELETE("/:id/lock", userEdit, adminUserController.UnlockUser)
+			}
+
+			adminOrder := adminAuth.Group("/orders")
+			adminOrder.Use(middleware.RequirePermission(model.PermOrderView))
+			{
+				adminOrder.GET("/list", adminOrderController.ListOrders)
+				adminOrder.GET("/:id", adminOrderController.GetOrder)
+				adminOrder.POST("/:id/cancel", middleware.RequirePermission(model.PermOrderCancel), adminOrderController.CancelOrder)
+				adminOrder.PUT("/:id/status", middleware.RequirePermission(model.PermOrderFulfil), adminOrderController.UpdateStatus)
+			}
+
+			adminRefund := adminAuth.Group("/refunds")
+			adminRefund.Use(middleware.RequirePermission(model.PermRefundManage))
+			{
+				adminRefund.GET("/list", adminRefundController.ListRefunds)
+				adminRefund.GET("/:id", adminRefundController.GetRefund)
+				adminRefund.POST("/:id/approve", adminRefundController.ApproveRefund)
+				adminRefund.POST("/:id/reject", adminRefundController.RejectRefund)
+			}
+
+			adminSeckill := adminAuth.Group("/seckill/campaigns")
+			adminSeckill.Use(middleware.RequirePermission(model.PermSeckillManage))
+			{
+				adminSeckill.GET("/list", adminSeckillController.ListCampaigns)
+				adminSeckill.POST("/create", adminSeckillController.CreateCampaign)
+				adminSeckill.GET("/:id", adminSeckillController.GetCampaign)
+				adminSeckill.PUT("/:id", adminSeckillController.UpdateCampaign)
+				adminSeckill.PUT("/:id/status", adminSeckillController.UpdateCampaignStatus)
+			}
+
+			adminRole := adminAuth.Group("/roles")
+			adminRole.Use(roleManage)
+			{
+				adminRole.GET("/list", adminRoleController.ListRoles)
+				adminRole.GET("/permissions", adminRoleController.ListPermissions)
+				adminRole.POST("/create", adminRoleController.CreateRole)
+				adminRole.PUT("/:id", adminRoleController.UpdateRole)
+				adminRole.DELETE("/:id", adminRoleController.DeleteRole)
+			}
+		}
+
 	}
 
 	captcha := v1.Group("/captcha")
 	{
 		captcha.GET("/generate", captchController.GenerateCaptcha)
 	}
+	// 签名公钥，供其他服务校验token
+	r.GET("/.well-known/jwks.json", userController.GetJWKS)
+
 	r.Static("/static", "./static/static")
 	r.NoRoute(func(c *gin.Context) {
 		c.File("./static/index.html")